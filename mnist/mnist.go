@@ -60,8 +60,8 @@ func OpenFile(path string) *os.File {
 	return file
 }
 
-func pixelWeight(px byte) neural.Float {
-	return neural.Float(px)/pixelRange*0.9 + 0.1
+func pixelWeight(px byte) float64 {
+	return float64(px)/pixelRange*0.9 + 0.1
 }
 
 func main() {
@@ -96,13 +96,16 @@ func main() {
 		net = neural.NewNetwork(width*height, hiddenNodes, numLabels)
 	} else {
 		fmt.Println("Loading network...")
-		net = neural.LoadNetwork(file)
+		if net, err = neural.LoadNetwork(file); err != nil {
+			fmt.Println(err)
+			os.Exit(-1)
+		}
 	}
 
-	input := make([]neural.Float, width*height)
-	expected := make([]neural.Float, numLabels)
+	input := make([]float64, width*height)
+	expected := make([]float64, numLabels)
 
-	epoch, worst, overall := 0, neural.Float(epsilon), neural.Float(0.0)
+	epoch, worst, overall := 0, float64(epsilon), float64(0.0)
 	for ; worst >= epsilon; epoch++ {
 		worst, overall = 0.0, 0.0
 		for i, labelIndex := range labelData {
@@ -127,7 +130,7 @@ func main() {
 				if *numSamples > 0 {
 					pctDone = int(float32(i) / float32(*numSamples) * 100.0)
 				}
-				fmt.Printf("\rEpoch #%d: %d%%, MSE = %.5f, worst = %.5f", epoch, pctDone, overall/neural.Float(i), worst)
+				fmt.Printf("\rEpoch #%d: %d%%, MSE = %.5f, worst = %.5f", epoch, pctDone, overall/float64(i), worst)
 			}
 			if *numSamples > 0 && *numSamples == i {
 				break
@@ -141,7 +144,7 @@ func main() {
 					input[j] = pixelWeight(testImageData[i][j])
 				}
 				result := net.Activate(input)
-				selected, maxValue := 0, neural.Float(-1.0)
+				selected, maxValue := 0, float64(-1.0)
 				for j, value := range result {
 					if value >= maxValue {
 						selected = j
@@ -157,7 +160,7 @@ func main() {
 			}
 		}
 
-		fmt.Printf("\rEpoch #%d: done, MSE = %.5f, worst = %.5f", epoch, overall/neural.Float(len(labelData)), worst)
+		fmt.Printf("\rEpoch #%d: done, MSE = %.5f, worst = %.5f", epoch, overall/float64(len(labelData)), worst)
 		if total > 0 {
 			fmt.Printf(", correct = %.2f%%", float32(correct)/float32(total)*100.0)
 		}
@@ -0,0 +1,281 @@
+package neural
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Activation is the nonlinearity a layer applies to its weighted sums.
+// Derivative is expressed in terms of the activated value, which suffices
+// for most activations; see SumDerivativeActivation for the others.
+type Activation interface {
+	Apply(x float64) float64
+	Derivative(activated float64) float64
+}
+
+// SumDerivativeActivation is implemented by activations whose derivative
+// is better expressed in terms of the sum they were applied to. Training
+// calls SumDerivative instead of Derivative for them.
+type SumDerivativeActivation interface {
+	Activation
+	SumDerivative(sum float64) float64
+}
+
+// Sigmoid is the logistic function, 1/(1+e^-x), whose derivative is
+// a(1-a) in terms of its value a. It's what layers use unless told
+// otherwise, and Sigmoid{}.Apply and Sigmoid{}.Derivative are the very
+// functions training calls, so custom training loops can use them too.
+type Sigmoid struct{}
+
+func (Sigmoid) Apply(x float64) float64 {
+	// Only ever exponentiate a non-positive number, so that large
+	// negative sums don't overflow to Inf on the way to 0.
+	if x >= 0 {
+		return 1.0 / (1.0 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1.0 + e)
+}
+
+func (Sigmoid) Derivative(activated float64) float64 {
+	return activated * (1.0 - activated)
+}
+
+type Tanh struct{}
+
+func (Tanh) Apply(x float64) float64 {
+	return math.Tanh(x)
+}
+
+func (Tanh) Derivative(activated float64) float64 {
+	return 1.0 - activated*activated
+}
+
+type ReLU struct{}
+
+func (ReLU) Apply(x float64) float64 {
+	return math.Max(0, x)
+}
+
+func (ReLU) Derivative(activated float64) float64 {
+	if activated > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (ReLU) SumDerivative(sum float64) float64 {
+	if sum > 0 {
+		return 1
+	}
+	return 0
+}
+
+// LeakyReLU is x for non-negative x, and Alpha*x otherwise, so units keep
+// a small gradient instead of dying. An Alpha of 0 means 0.01.
+type LeakyReLU struct {
+	Alpha float64
+}
+
+func (a LeakyReLU) alpha() float64 {
+	if a.Alpha > 0 {
+		return a.Alpha
+	}
+	return 0.01
+}
+
+func (a LeakyReLU) Apply(x float64) float64 {
+	if x >= 0 {
+		return x
+	}
+	return a.alpha() * x
+}
+
+func (a LeakyReLU) Derivative(activated float64) float64 {
+	if activated >= 0 {
+		return 1
+	}
+	return a.alpha()
+}
+
+func (a LeakyReLU) SumDerivative(sum float64) float64 {
+	if sum >= 0 {
+		return 1
+	}
+	return a.alpha()
+}
+
+type Linear struct{}
+
+func (Linear) Apply(x float64) float64 {
+	return x
+}
+
+func (Linear) Derivative(activated float64) float64 {
+	return 1
+}
+
+// Softplus is log(1 + e^x), a smooth ReLU.
+type Softplus struct{}
+
+func (Softplus) Apply(x float64) float64 {
+	// Equal to log(1 + e^x), without overflowing for large x.
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+// Derivative is the logistic sigmoid of the sum, which for an activated
+// value y is 1 - e^-y.
+func (Softplus) Derivative(activated float64) float64 {
+	return -math.Expm1(-activated)
+}
+
+// ELU is x for positive x, and Alpha*(e^x - 1) otherwise, which saturates
+// at -Alpha. An Alpha of 0 means 1.
+type ELU struct {
+	Alpha float64
+}
+
+func (a ELU) alpha() float64 {
+	if a.Alpha > 0 {
+		return a.Alpha
+	}
+	return 1
+}
+
+func (a ELU) Apply(x float64) float64 {
+	if x > 0 {
+		return x
+	}
+	return a.alpha() * math.Expm1(x)
+}
+
+func (a ELU) Derivative(activated float64) float64 {
+	if activated > 0 {
+		return 1
+	}
+	return activated + a.alpha()
+}
+
+// VectorActivation is implemented by activations whose output at each node
+// depends on the sums of the whole layer. Layers call ApplyVector instead
+// of Apply; out and sums may be the same slice.
+type VectorActivation interface {
+	Activation
+	ApplyVector(sums, out []float64)
+}
+
+// Softmax normalizes a layer's outputs into a probability distribution.
+// Its Derivative is only the diagonal of the softmax Jacobian, so training
+// backpropagates through the full Jacobian instead. As an output layer it
+// pairs best with the CrossEntropy loss, whose gradient at the sums is
+// then simply result - expected.
+type Softmax struct{}
+
+// Apply returns the unnormalized exponential of x.
+func (Softmax) Apply(x float64) float64 {
+	return math.Exp(x)
+}
+
+func (Softmax) Derivative(activated float64) float64 {
+	return activated * (1.0 - activated)
+}
+
+func (Softmax) ApplyVector(sums, out []float64) {
+	max, infinite := math.Inf(-1), 0
+	for _, sum := range sums {
+		if sum > max {
+			max = sum
+		}
+		if math.IsInf(sum, 1) {
+			infinite++
+		}
+	}
+	// Infinite logits share all of the probability mass; otherwise
+	// subtracting the max keeps every exponent at or below zero.
+	if infinite > 0 {
+		for i, sum := range sums {
+			out[i] = 0
+			if math.IsInf(sum, 1) {
+				out[i] = 1.0 / float64(infinite)
+			}
+		}
+		return
+	}
+	total := 0.0
+	for i, sum := range sums {
+		out[i] = math.Exp(sum - max)
+		total += out[i]
+	}
+	for i := range out {
+		out[i] /= total
+	}
+}
+
+var activations = newRegistry("activation")
+
+func init() {
+	RegisterActivation("Sigmoid", Sigmoid{})
+	RegisterActivation("Tanh", Tanh{})
+	RegisterActivation("ReLU", ReLU{})
+	RegisterActivation("LeakyReLU", LeakyReLU{})
+	RegisterActivation("Linear", Linear{})
+	RegisterActivation("Softmax", Softmax{})
+	RegisterActivation("Softplus", Softplus{})
+	RegisterActivation("ELU", ELU{})
+}
+
+// RegisterActivation makes a custom activation known to Save and
+// LoadNetwork under the given name. Any exported fields of the activation
+// are saved along with it.
+func RegisterActivation(name string, a Activation) {
+	activations.register(name, a)
+}
+
+func (layer *Layer) activation() Activation {
+	if layer.Activation == nil {
+		return Sigmoid{}
+	}
+	return layer.Activation
+}
+
+func marshalActivation(a Activation) (name string, params json.RawMessage, err error) {
+	return activations.marshal(a)
+}
+
+func unmarshalActivation(name string, params json.RawMessage) (Activation, error) {
+	if name == "" {
+		return Sigmoid{}, nil
+	}
+	a, err := activations.unmarshal(name, params)
+	if err != nil {
+		return nil, err
+	}
+	return a.(Activation), nil
+}
+
+// layerPlain has Layer's fields without its JSON methods, so they can be
+// embedded in the encoded form below.
+type layerPlain Layer
+
+type layerJSON struct {
+	*layerPlain
+	Activation       string          `json:"activation,omitempty"`
+	ActivationParams json.RawMessage `json:"activationParams,omitempty"`
+}
+
+func (layer *Layer) MarshalJSON() ([]byte, error) {
+	name, params, err := marshalActivation(layer.activation())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(layerJSON{(*layerPlain)(layer), name, params})
+}
+
+func (layer *Layer) UnmarshalJSON(data []byte) (err error) {
+	saved := layerJSON{layerPlain: (*layerPlain)(layer)}
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return
+	}
+	layer.Activation, err = unmarshalActivation(saved.Activation, saved.ActivationParams)
+	return
+}
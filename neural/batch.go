@@ -0,0 +1,89 @@
+package neural
+
+import (
+	"fmt"
+	"sync"
+)
+
+// forward computes the layer's output for input into out, without
+// touching any of the layer's caches.
+func (layer *Layer) forward(input []float64, out []float64) {
+	layer.weightedSum(input, out)
+	if layer.BatchNorm != nil {
+		layer.BatchNorm.apply(out)
+	}
+	layer.activate(out)
+}
+
+// newScratch allocates a buffer for each layer's output, for forward.
+func (net *Network) newScratch() [][]float64 {
+	scratch := make([][]float64, len(net.Layers))
+	for i, layer := range net.Layers {
+		scratch[i] = make([]float64, len(layer.Weight))
+	}
+	return scratch
+}
+
+// forward runs input through every layer using the caller's scratch
+// buffers, and returns the last of them.
+func (net *Network) forward(input []float64, scratch [][]float64) []float64 {
+	output := input
+	for i, layer := range net.Layers {
+		layer.forward(output, scratch[i])
+		output = scratch[i]
+	}
+	return output
+}
+
+// scratchPool holds the buffers ActivateInto uses for the outputs of
+// hidden layers, each a *[]float64 resliced to fit whichever network takes
+// it.
+var scratchPool = sync.Pool{New: func() interface{} { return new([]float64) }}
+
+// ActivateInto is Activate, writing the output into dst instead of a new
+// slice, and reusing its buffers for the hidden layers from one call to
+// the next, so that scoring in a loop doesn't allocate. It panics if input
+// isn't InputSize long or dst doesn't have room for exactly one output per
+// output node. Like Activate, it's safe to call from several goroutines at
+// once.
+func (net *Network) ActivateInto(input []float64, dst []float64) {
+	if err := net.checkInput(input); err != nil {
+		panic(err)
+	}
+	if outputs := len(net.outputLayer().Weight); len(dst) != outputs {
+		panic(fmt.Errorf("neural: got a buffer for %d outputs, network has %d", len(dst), outputs))
+	}
+	buf := scratchPool.Get().(*[]float64)
+	n := 0
+	for _, layer := range net.Layers[:len(net.Layers)-1] {
+		n += len(layer.Weight)
+	}
+	if cap(*buf) < n {
+		*buf = make([]float64, n)
+	}
+	free := (*buf)[:n]
+	output := input
+	for i, layer := range net.Layers {
+		out := dst
+		if i < len(net.Layers)-1 {
+			out, free = free[:len(layer.Weight)], free[len(layer.Weight):]
+		}
+		layer.forward(output, out)
+		output = out
+	}
+	scratchPool.Put(buf)
+}
+
+// ActivateBatch runs every input through the network, spreading the work
+// over one goroutine per CPU, and returns the outputs in the same order.
+// Like Activate, it panics if any input isn't InputSize long. Building with
+// -tags gonum computes the whole batch as one matrix product per layer
+// with gonum instead, which is much faster for wide layers.
+func (net *Network) ActivateBatch(inputs [][]float64) [][]float64 {
+	for _, input := range inputs {
+		if err := net.checkInput(input); err != nil {
+			panic(err)
+		}
+	}
+	return net.activateBatch(inputs)
+}
@@ -0,0 +1,43 @@
+//go:build gonum
+
+package neural
+
+import "gonum.org/v1/gonum/mat"
+
+// activateBatch is ActivateBatch, feeding the whole batch through each
+// layer as one matrix product with gonum, which leaves it to BLAS.
+func (net *Network) activateBatch(inputs [][]float64) [][]float64 {
+	results := make([][]float64, len(inputs))
+	if len(inputs) == 0 {
+		return results
+	}
+	x := mat.NewDense(len(inputs), net.InputSize(), nil)
+	for k, input := range inputs {
+		x.SetRow(k, input)
+	}
+	for _, layer := range net.Layers {
+		weights := mat.NewDense(len(layer.Weight), layer.inputs(), nil)
+		for i, weight := range layer.Weight {
+			for j, w := range weight {
+				weights.Set(i, j, float64(w))
+			}
+		}
+		sums := mat.NewDense(len(inputs), len(layer.Weight), nil)
+		sums.Mul(x, weights.T())
+		for k := range inputs {
+			row := sums.RawRowView(k)
+			for i, b := range layer.Bias {
+				row[i] += float64(b)
+			}
+			if layer.BatchNorm != nil {
+				layer.BatchNorm.apply(row)
+			}
+			layer.activate(row)
+		}
+		x = sums
+	}
+	for k := range results {
+		results[k] = mat.Row(nil, k, x)
+	}
+	return results
+}
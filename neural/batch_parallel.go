@@ -0,0 +1,36 @@
+//go:build !gonum
+
+package neural
+
+import (
+	"runtime"
+	"sync"
+)
+
+// activateBatch is ActivateBatch, with one goroutine per CPU each taking
+// inputs from a shared queue.
+func (net *Network) activateBatch(inputs [][]float64) [][]float64 {
+	results := make([][]float64, len(inputs))
+	workers := runtime.NumCPU()
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			scratch := net.newScratch()
+			for i := range jobs {
+				results[i] = copyFloats(net.forward(inputs[i], scratch))
+			}
+		}()
+	}
+	for i := range inputs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,192 @@
+package neural
+
+import "math"
+
+const (
+	batchNormEpsilon = 1e-5
+	// batchNormMomentum is the weight the running statistics give their
+	// old value each time a batch is folded in.
+	batchNormMomentum = 0.9
+)
+
+// BatchNorm normalizes a layer's weighted sums to zero mean and unit
+// variance before its activation, then scales them by Gamma and shifts them
+// by Beta, both of which are learned. TrainBatch normalizes using the
+// statistics of each batch and folds them into the running Mean and
+// Variance, which Activate and the single-example trainers use instead.
+type BatchNorm struct {
+	Gamma    []float64 `json:"gamma"`
+	Beta     []float64 `json:"beta"`
+	Mean     []float64 `json:"mean"`
+	Variance []float64 `json:"variance"`
+}
+
+func NewBatchNorm(nodes int) *BatchNorm {
+	bn := &BatchNorm{
+		Gamma:    make([]float64, nodes),
+		Beta:     make([]float64, nodes),
+		Mean:     make([]float64, nodes),
+		Variance: make([]float64, nodes),
+	}
+	for i := 0; i < nodes; i++ {
+		bn.Gamma[i] = 1
+		bn.Variance[i] = 1
+	}
+	return bn
+}
+
+// normalize replaces sums with their scaled and shifted normalization under
+// the running statistics, recording the normalized sums in normalized.
+func (bn *BatchNorm) normalize(sums []float64, normalized []float64) []float64 {
+	if len(normalized) != len(sums) {
+		normalized = make([]float64, len(sums))
+	}
+	for i, sum := range sums {
+		normalized[i] = (sum - bn.Mean[i]) / math.Sqrt(bn.Variance[i]+batchNormEpsilon)
+		sums[i] = bn.Gamma[i]*normalized[i] + bn.Beta[i]
+	}
+	return normalized
+}
+
+// apply is normalize without recording the normalized sums.
+func (bn *BatchNorm) apply(sums []float64) {
+	for i, sum := range sums {
+		sums[i] = bn.Gamma[i]*(sum-bn.Mean[i])/math.Sqrt(bn.Variance[i]+batchNormEpsilon) + bn.Beta[i]
+	}
+}
+
+// backward turns delta, the gradient with respect to the normalized output,
+// into the gradient with respect to the sums, treating the running
+// statistics as constants.
+func (bn *BatchNorm) backward(delta []float64, normalized []float64, grad *gradient) {
+	for i := range delta {
+		grad.Gamma[i] += delta[i] * normalized[i]
+		grad.Beta[i] += delta[i]
+		delta[i] *= bn.Gamma[i] / math.Sqrt(bn.Variance[i]+batchNormEpsilon)
+	}
+}
+
+// normalizeBatch is normalize using the statistics of the batch itself,
+// which are also folded into the running ones. It returns the normalized
+// sums of each example and the inverse standard deviation at each node.
+func (bn *BatchNorm) normalizeBatch(sums [][]float64) (normalized [][]float64, invStd []float64) {
+	n := float64(len(sums))
+	normalized = make([][]float64, len(sums))
+	for k := range sums {
+		normalized[k] = make([]float64, len(bn.Gamma))
+	}
+	invStd = make([]float64, len(bn.Gamma))
+	for i := range bn.Gamma {
+		mean, variance := 0.0, 0.0
+		for k := range sums {
+			mean += sums[k][i]
+		}
+		mean /= n
+		for k := range sums {
+			variance += (sums[k][i] - mean) * (sums[k][i] - mean)
+		}
+		variance /= n
+		invStd[i] = 1.0 / math.Sqrt(variance+batchNormEpsilon)
+		for k := range sums {
+			normalized[k][i] = (sums[k][i] - mean) * invStd[i]
+			sums[k][i] = bn.Gamma[i]*normalized[k][i] + bn.Beta[i]
+		}
+		bn.Mean[i] = batchNormMomentum*bn.Mean[i] + (1-batchNormMomentum)*mean
+		bn.Variance[i] = batchNormMomentum*bn.Variance[i] + (1-batchNormMomentum)*variance
+	}
+	return
+}
+
+// backwardBatch is backward through normalizeBatch, where every example's
+// sums also moved the batch's mean and variance.
+func (bn *BatchNorm) backwardBatch(deltas [][]float64, normalized [][]float64, invStd []float64, grad *gradient) {
+	n := float64(len(deltas))
+	for i := range bn.Gamma {
+		sumDelta, sumDeltaNorm := 0.0, 0.0
+		for k := range deltas {
+			grad.Gamma[i] += deltas[k][i] * normalized[k][i]
+			grad.Beta[i] += deltas[k][i]
+			d := deltas[k][i] * bn.Gamma[i]
+			sumDelta += d
+			sumDeltaNorm += d * normalized[k][i]
+		}
+		for k := range deltas {
+			d := deltas[k][i] * bn.Gamma[i]
+			deltas[k][i] = invStd[i] / n * (n*d - sumDelta - normalized[k][i]*sumDeltaNorm)
+		}
+	}
+}
+
+func (net *Network) hasBatchNorm() bool {
+	for _, layer := range net.Layers {
+		if layer.BatchNorm != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// accumulateBatchNorm accumulates the gradients of a batch for networks
+// with batch normalization. The whole batch has to go through each layer
+// before the next, so it keeps every example's values rather than using
+// the layers' caches.
+func (net *Network) accumulateBatchNorm(inputs [][]float64, expecteds [][]float64, weights []float64) float64 {
+	last := len(net.Layers) - 1
+	layerInputs := make([][][]float64, len(net.Layers))
+	sums := make([][][]float64, len(net.Layers))
+	values := make([][][]float64, len(net.Layers))
+	masks := make([][][]float64, len(net.Layers))
+	normalized := make([][][]float64, len(net.Layers))
+	invStd := make([][]float64, len(net.Layers))
+	in := inputs
+	for l, layer := range net.Layers {
+		layerInputs[l] = in
+		out := make([][]float64, len(in))
+		for k := range in {
+			out[k] = make([]float64, len(layer.Weight))
+			layer.weightedSum(in[k], out[k])
+		}
+		if layer.BatchNorm != nil {
+			normalized[l], invStd[l] = layer.BatchNorm.normalizeBatch(out)
+		}
+		sums[l] = make([][]float64, len(in))
+		masks[l] = make([][]float64, len(in))
+		for k := range out {
+			sums[l][k] = copyFloats(out[k])
+			layer.activate(out[k])
+			if l < last && layer.Dropout > 0 {
+				masks[l][k] = make([]float64, len(out[k]))
+				layer.dropout(out[k], masks[l][k], net.rand())
+			}
+		}
+		values[l] = out
+		in = out
+	}
+
+	loss := 0.0
+	deltas := make([][]float64, len(inputs))
+	for k := range deltas {
+		weight := sampleWeight(weights, k)
+		loss += weight * net.cost(values[last][k], expecteds[k])
+		deltas[k] = net.outputDeltaFrom(sums[last][k], values[last][k], expecteds[k])
+		if weight != 1 {
+			for i := range deltas[k] {
+				deltas[k][i] *= weight
+			}
+		}
+	}
+	for l := last; l >= 0; l-- {
+		layer := net.Layers[l]
+		grad := layer.gradient()
+		if layer.BatchNorm != nil {
+			layer.BatchNorm.backwardBatch(deltas, normalized[l], invStd[l], grad)
+		}
+		for k := range deltas {
+			residual := layer.backpropagate(layerInputs[l][k], deltas[k], grad)
+			if l > 0 {
+				deltas[k] = net.Layers[l-1].deltaFrom(residual, sums[l-1][k], values[l-1][k], masks[l-1][k])
+			}
+		}
+	}
+	return loss
+}
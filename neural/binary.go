@@ -0,0 +1,275 @@
+package neural
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// The format written by SaveBinary: a header with the network's settings,
+// then each layer's dimensions and settings followed by its parameters as
+// raw little-endian float64s, row by row.
+const (
+	binaryMagic   = "NNRB"
+	binaryVersion = 1
+	// binaryMaxString bounds the names and parameters read back, so that
+	// a corrupt length can't ask for gigabytes.
+	binaryMaxString = 1 << 20
+)
+
+const (
+	binaryHasBias = 1 << iota
+	binaryHasBatchNorm
+	binaryFrozen
+)
+
+// SaveBinary writes the network in a fixed binary layout: a short header
+// giving each layer's dimensions, then its weights and biases as raw
+// float64 blocks. LoadNetworkBinary reads it without building any
+// intermediate representation, so large networks load quickly and in
+// little more memory than they take. Like Save, it doesn't include
+// optimizer state.
+func (net *Network) SaveBinary(w io.Writer) error {
+	lossName, lossParams, err := losses.marshal(net.loss())
+	if err != nil {
+		return err
+	}
+	var optName string
+	var optParams []byte
+	if net.Optimizer != nil {
+		if optName, optParams, err = optimizers.marshal(net.Optimizer); err != nil {
+			return err
+		}
+	}
+	bw := &binaryWriter{w: bufio.NewWriter(w)}
+	bw.bytes([]byte(binaryMagic))
+	bw.uint32(binaryVersion)
+	bw.uint32(len(net.Layers))
+	bw.string(lossName)
+	bw.string(string(lossParams))
+	bw.string(optName)
+	bw.string(string(optParams))
+	bw.float64(net.WeightDecay)
+	for _, layer := range net.Layers {
+		name, params, err := marshalActivation(layer.activation())
+		if err != nil {
+			return err
+		}
+		var flags byte
+		if layer.Bias != nil {
+			flags |= binaryHasBias
+		}
+		if layer.BatchNorm != nil {
+			flags |= binaryHasBatchNorm
+		}
+		if layer.Frozen {
+			flags |= binaryFrozen
+		}
+		bw.uint32(len(layer.Weight))
+		bw.uint32(layer.inputs())
+		bw.bytes([]byte{flags})
+		bw.float64(layer.Dropout)
+		bw.float64(layer.RateScale)
+		bw.string(name)
+		bw.string(string(params))
+		for _, weight := range layer.Weight {
+			for _, w := range weight {
+				bw.float64(float64(w))
+			}
+		}
+		for _, b := range layer.Bias {
+			bw.float64(float64(b))
+		}
+		if bn := layer.BatchNorm; bn != nil {
+			for _, v := range [][]float64{bn.Gamma, bn.Beta, bn.Mean, bn.Variance} {
+				for _, x := range v {
+					bw.float64(x)
+				}
+			}
+		}
+	}
+	if bw.err != nil {
+		return bw.err
+	}
+	return bw.w.Flush()
+}
+
+// LoadNetworkBinary decodes a network written by SaveBinary.
+func LoadNetworkBinary(r io.Reader) (*Network, error) {
+	net, err := loadNetworkBinary(&binaryReader{r: bufio.NewReader(r)})
+	if err != nil {
+		return nil, fmt.Errorf("neural: decoding network: %v", err)
+	}
+	if err := net.initialize(); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+func loadNetworkBinary(br *binaryReader) (*Network, error) {
+	if magic := string(br.bytes(len(binaryMagic))); br.err == nil && magic != binaryMagic {
+		return nil, fmt.Errorf("not a binary network")
+	}
+	if version := br.uint32(); br.err == nil && version != binaryVersion {
+		return nil, fmt.Errorf("unsupported binary version %d (this package reads version %d)", version, binaryVersion)
+	}
+	count := br.uint32()
+	lossName, lossParams := br.string(), br.string()
+	optName, optParams := br.string(), br.string()
+	net := &Network{WeightDecay: br.float64()}
+	if br.err != nil {
+		return nil, br.err
+	}
+	var err error
+	if net.Loss, err = unmarshalLoss(lossName, []byte(lossParams)); err != nil {
+		return nil, err
+	}
+	if net.Optimizer, err = unmarshalOptimizer(optName, []byte(optParams)); err != nil {
+		return nil, err
+	}
+	for i := 0; i < count; i++ {
+		nodes, inputs := br.uint32(), br.uint32()
+		if br.err == nil && (nodes < 1 || inputs < 1) {
+			return nil, fmt.Errorf("layer %d has %d nodes and %d inputs", i, nodes, inputs)
+		}
+		flags := br.bytes(1)
+		layer := &Layer{Dropout: br.float64(), RateScale: br.float64()}
+		name, params := br.string(), br.string()
+		if br.err != nil {
+			return nil, br.err
+		}
+		if layer.Activation, err = unmarshalActivation(name, []byte(params)); err != nil {
+			return nil, err
+		}
+		layer.Frozen = flags[0]&binaryFrozen != 0
+		// The dimensions come from the file, so nothing is allocated for
+		// values until they've actually been read.
+		for j := 0; j < nodes && br.err == nil; j++ {
+			layer.Weight = append(layer.Weight, br.floats(inputs))
+		}
+		if flags[0]&binaryHasBias != 0 {
+			layer.Bias = br.floats(nodes)
+		}
+		if flags[0]&binaryHasBatchNorm != 0 {
+			bn := &BatchNorm{}
+			for _, v := range []*[]float64{&bn.Gamma, &bn.Beta, &bn.Mean, &bn.Variance} {
+				*v = br.float64s(nodes)
+			}
+			layer.BatchNorm = bn
+		}
+		if br.err != nil {
+			return nil, br.err
+		}
+		net.Layers = append(net.Layers, layer)
+	}
+	return net, nil
+}
+
+// binaryWriter writes the fields of SaveBinary's format, keeping the first
+// error so that it's only checked once at the end.
+type binaryWriter struct {
+	w   *bufio.Writer
+	buf [8]byte
+	err error
+}
+
+func (bw *binaryWriter) bytes(b []byte) {
+	if bw.err == nil {
+		_, bw.err = bw.w.Write(b)
+	}
+}
+
+func (bw *binaryWriter) uint32(n int) {
+	binary.LittleEndian.PutUint32(bw.buf[:4], uint32(n))
+	bw.bytes(bw.buf[:4])
+}
+
+func (bw *binaryWriter) float64(x float64) {
+	binary.LittleEndian.PutUint64(bw.buf[:], math.Float64bits(x))
+	bw.bytes(bw.buf[:])
+}
+
+func (bw *binaryWriter) string(s string) {
+	bw.uint32(len(s))
+	bw.bytes([]byte(s))
+}
+
+// binaryReader reads what binaryWriter writes. After an error it keeps
+// returning zero values, and err holds the error.
+type binaryReader struct {
+	r   *bufio.Reader
+	buf [8]byte
+	err error
+}
+
+func (br *binaryReader) bytes(n int) []byte {
+	b := make([]byte, n)
+	if br.err == nil {
+		if _, err := io.ReadFull(br.r, b); err != nil {
+			br.err = err
+		}
+	}
+	return b
+}
+
+func (br *binaryReader) uint32() int {
+	if br.err == nil {
+		if _, br.err = io.ReadFull(br.r, br.buf[:4]); br.err == nil {
+			return int(binary.LittleEndian.Uint32(br.buf[:4]))
+		}
+	}
+	return 0
+}
+
+func (br *binaryReader) float64() float64 {
+	if br.err == nil {
+		if _, br.err = io.ReadFull(br.r, br.buf[:]); br.err == nil {
+			return math.Float64frombits(binary.LittleEndian.Uint64(br.buf[:]))
+		}
+	}
+	return 0
+}
+
+func (br *binaryReader) string() string {
+	n := br.uint32()
+	if n > binaryMaxString {
+		if br.err == nil {
+			br.err = fmt.Errorf("string of %d bytes is too long", n)
+		}
+		return ""
+	}
+	return string(br.bytes(n))
+}
+
+// binaryChunk is the most values floats and float64s make room for up
+// front, so that a corrupt count runs out of input before it runs out of
+// memory.
+const binaryChunk = 4096
+
+func binaryCapacity(n int) int {
+	if n > binaryChunk {
+		return binaryChunk
+	}
+	return n
+}
+
+// floats reads n float64s as Floats, growing the slice only as they
+// arrive.
+func (br *binaryReader) floats(n int) []Float {
+	v := make([]Float, 0, binaryCapacity(n))
+	for len(v) < n && br.err == nil {
+		v = append(v, Float(br.float64()))
+	}
+	return v
+}
+
+// float64s is floats for float64s.
+func (br *binaryReader) float64s(n int) []float64 {
+	v := make([]float64, 0, binaryCapacity(n))
+	for len(v) < n && br.err == nil {
+		v = append(v, br.float64())
+	}
+	return v
+}
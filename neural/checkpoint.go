@@ -0,0 +1,111 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SaveWithOptimizer is Save, but also writes the state the optimizer has
+// built up, like TrainMomentum's velocity, so that a network loaded with
+// LoadNetwork resumes training exactly where it left off.
+func (net *Network) SaveWithOptimizer(w io.Writer) error {
+	saved, err := net.saved()
+	if err != nil {
+		return err
+	}
+	for _, layer := range net.Layers {
+		if layer.stateType == nil {
+			continue
+		}
+		name, ok := optimizers.names[layer.stateType]
+		if !ok {
+			return fmt.Errorf("neural: unregistered optimizer %v", layer.stateType)
+		}
+		if saved.StateOptimizer != "" && saved.StateOptimizer != name {
+			return fmt.Errorf("neural: layers hold state for both %s and %s optimizers", saved.StateOptimizer, name)
+		}
+		saved.StateOptimizer = name
+	}
+	if saved.StateOptimizer != "" {
+		saved.OptimizerState = make([][]float64, len(net.Layers))
+		for i, layer := range net.Layers {
+			saved.OptimizerState[i] = layer.state
+		}
+	}
+	return json.NewEncoder(w).Encode(saved)
+}
+
+func (net *Network) restoreState(saved *savedNetwork) error {
+	state, name := saved.OptimizerState, saved.StateOptimizer
+	if saved.Velocity != nil {
+		var err error
+		if state, err = net.velocityState(saved.Velocity); err != nil {
+			return err
+		}
+		name = "Momentum"
+	}
+	if state == nil {
+		return nil
+	}
+	if name == "" {
+		return fmt.Errorf("neural: decoding network: optimizer state without an optimizer")
+	}
+	if len(state) != len(net.Layers) {
+		return fmt.Errorf("neural: decoding network: optimizer state for %d layers, network has %d",
+			len(state), len(net.Layers))
+	}
+	opt, err := unmarshalOptimizer(name, nil)
+	if err != nil {
+		return fmt.Errorf("neural: decoding network: %v", err)
+	}
+	for i, layer := range net.Layers {
+		if state[i] == nil {
+			continue
+		}
+		if len(state[i]) != len(layer.optimizerState(opt)) {
+			return fmt.Errorf("neural: decoding network: optimizer state doesn't fit %s layer", net.layerName(i))
+		}
+		layer.state = state[i]
+	}
+	return nil
+}
+
+// velocityState converts momentum saved per layer as a gradient, as files
+// did before OptimizerState, into Momentum's state.
+func (net *Network) velocityState(velocity []*gradient) ([][]float64, error) {
+	if len(velocity) != len(net.Layers) {
+		return nil, fmt.Errorf("neural: decoding network: optimizer state for %d layers, network has %d",
+			len(velocity), len(net.Layers))
+	}
+	state := make([][]float64, len(velocity))
+	for i, v := range velocity {
+		if v == nil {
+			continue
+		}
+		layer := net.Layers[i]
+		if !v.fits(layer) {
+			return nil, fmt.Errorf("neural: decoding network: optimizer state doesn't fit %s layer", net.layerName(i))
+		}
+		for j, weight := range v.Weight {
+			state[i] = append(state[i], weight...)
+			if layer.Bias != nil {
+				state[i] = append(state[i], v.Bias[j])
+			}
+		}
+	}
+	return state, nil
+}
+
+// fits reports whether grad has the shape of layer's weights and biases.
+func (grad *gradient) fits(layer *Layer) bool {
+	if len(grad.Weight) != len(layer.Weight) || len(grad.Bias) != len(layer.Bias) {
+		return false
+	}
+	for i, weight := range layer.Weight {
+		if len(grad.Weight[i]) != len(weight) {
+			return false
+		}
+	}
+	return true
+}
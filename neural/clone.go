@@ -0,0 +1,71 @@
+package neural
+
+// Clone returns a deep copy of the network, including the values cached by
+// the last forward pass and any optimizer state, so the copy can carry on
+// training exactly where the original is without either affecting the
+// other. The one thing they share is the network's Rand, if it has one.
+func (net *Network) Clone() *Network {
+	clone := new(Network)
+	*clone = *net
+	clone.Layers = make([]*Layer, len(net.Layers))
+	for i, layer := range net.Layers {
+		clone.Layers[i] = layer.clone()
+	}
+	return clone
+}
+
+func (layer *Layer) clone() *Layer {
+	clone := &Layer{
+		Weight:     copyWeights(layer.Weight),
+		Bias:       copyBias(layer.Bias),
+		Activation: layer.Activation,
+		Dropout:    layer.Dropout,
+		Frozen:     layer.Frozen,
+		RateScale:  layer.RateScale,
+		sum:        copyFloats(layer.sum),
+		value:      copyFloats(layer.value),
+		normalized: copyFloats(layer.normalized),
+		mask:       copyFloats(layer.mask),
+		masked:     layer.masked,
+		state:      copyFloats(layer.state),
+		stateType:  layer.stateType,
+	}
+	if bn := layer.BatchNorm; bn != nil {
+		clone.BatchNorm = &BatchNorm{
+			Gamma:    copyFloats(bn.Gamma),
+			Beta:     copyFloats(bn.Beta),
+			Mean:     copyFloats(bn.Mean),
+			Variance: copyFloats(bn.Variance),
+		}
+	}
+	return clone
+}
+
+func copyFloats(v []float64) []float64 {
+	if v == nil {
+		return nil
+	}
+	c := make([]float64, len(v))
+	copy(c, v)
+	return c
+}
+
+func copyBias(v []Float) []Float {
+	if v == nil {
+		return nil
+	}
+	c := make([]Float, len(v))
+	copy(c, v)
+	return c
+}
+
+func copyWeights(m [][]Float) [][]Float {
+	if m == nil {
+		return nil
+	}
+	c := make([][]Float, len(m))
+	for i, row := range m {
+		c[i] = copyBias(row)
+	}
+	return c
+}
@@ -0,0 +1,28 @@
+package neural
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// SaveCompressed is Save, with the JSON compressed by gzip. The gzip
+// stream is closed before it returns, but w itself isn't.
+func (net *Network) SaveCompressed(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := net.Save(zw); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// LoadNetworkCompressed decodes a network written by SaveCompressed.
+func LoadNetworkCompressed(r io.Reader) (*Network, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("neural: decoding network: %v", err)
+	}
+	defer zr.Close()
+	return LoadNetwork(zr)
+}
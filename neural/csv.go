@@ -0,0 +1,69 @@
+package neural
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// ExportCSV writes every layer's parameters as a series of CSV blocks
+// separated by blank lines. Each block starts with a label row naming the
+// layer and parameter, like "Hidden,Weight", followed by one row per node
+// for weights, or a single row for biases and batch normalization vectors.
+// Layers without biases have no bias block.
+func (net *Network) ExportCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	block := func(name string, param string, rows [][]string) error {
+		if err := out.Write([]string{name, param}); err != nil {
+			return err
+		}
+		// WriteAll flushes, so the separator lands after the rows.
+		if err := out.WriteAll(rows); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+	for i, layer := range net.Layers {
+		name := net.layerName(i)
+		weights := make([][]string, len(layer.Weight))
+		for j, weight := range layer.Weight {
+			weights[j] = formatWeights(weight)
+		}
+		if err := block(name, "Weight", weights); err != nil {
+			return err
+		}
+		if layer.Bias != nil {
+			if err := block(name, "Bias", [][]string{formatWeights(layer.Bias)}); err != nil {
+				return err
+			}
+		}
+		if bn := layer.BatchNorm; bn != nil {
+			for _, param := range []struct {
+				name   string
+				values []float64
+			}{{"Gamma", bn.Gamma}, {"Beta", bn.Beta}, {"Mean", bn.Mean}, {"Variance", bn.Variance}} {
+				if err := block(name, param.name, [][]string{formatFloats(param.values)}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func formatWeights(v []Float) []string {
+	s := make([]string, len(v))
+	for i, x := range v {
+		s[i] = strconv.FormatFloat(float64(x), 'g', -1, floatBits)
+	}
+	return s
+}
+
+func formatFloats(v []float64) []string {
+	s := make([]string, len(v))
+	for i, x := range v {
+		s[i] = strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package neural
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ExportDOT writes the network as a GraphViz digraph, with a cluster of
+// nodes for the inputs and for each layer. Only weights whose magnitude
+// exceeds threshold get an edge; positive weights are blue and negative
+// ones red, and heavier weights are drawn thicker.
+func (net *Network) ExportDOT(w io.Writer, threshold float64) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph network {\n\trankdir=LR;\n\tnode [shape=circle];\n")
+	column := func(prefix string, label string, n int) string {
+		fmt.Fprintf(&buf, "\tsubgraph cluster_%s {\n\t\tlabel=\"%s\";\n", prefix, label)
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&buf, "\t\t%s%d [label=\"%d\"];\n", prefix, i, i)
+		}
+		buf.WriteString("\t}\n")
+		return prefix
+	}
+	from := column("in_", "Input", net.InputSize())
+	heaviest := 0.0
+	for _, layer := range net.Layers {
+		for _, weight := range layer.Weight {
+			for _, x := range weight {
+				heaviest = math.Max(heaviest, math.Abs(float64(x)))
+			}
+		}
+	}
+	for i, layer := range net.Layers {
+		to := column(fmt.Sprintf("l%d_", i+1), net.layerName(i), len(layer.Weight))
+		for j, weight := range layer.Weight {
+			for k, x := range weight {
+				magnitude := math.Abs(float64(x))
+				if magnitude <= threshold {
+					continue
+				}
+				color := "blue"
+				if x < 0 {
+					color = "red"
+				}
+				fmt.Fprintf(&buf, "\t%s%d -> %s%d [color=%s, penwidth=%.2f];\n",
+					from, k, to, j, color, 0.5+4.5*magnitude/heaviest)
+			}
+		}
+		from = to
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,53 @@
+package neural
+
+import "math/rand"
+
+// applyDropout zeroes each of the layer's values with probability
+// layer.Dropout and scales the survivors by 1/(1-Dropout), so nothing
+// needs rescaling at inference time. The mask is kept for delta.
+func (layer *Layer) applyDropout(rng *rand.Rand) {
+	if layer.Dropout <= 0 {
+		return
+	}
+	if len(layer.mask) != len(layer.value) {
+		layer.mask = make([]float64, len(layer.value))
+	}
+	layer.dropout(layer.value, layer.mask, rng)
+	layer.masked = true
+}
+
+// dropout fills mask with each unit's dropout scale, drawn from rng, and
+// applies it to values.
+func (layer *Layer) dropout(values []float64, mask []float64, rng *rand.Rand) {
+	keep := 1.0 - layer.Dropout
+	for i := range values {
+		mask[i] = 0
+		if rng.Float64() < keep {
+			mask[i] = 1.0 / keep
+		}
+		values[i] *= mask[i]
+	}
+}
+
+func (net *Network) hasDropout() bool {
+	for _, layer := range net.Layers[:len(net.Layers)-1] {
+		if layer.Dropout > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// feedforwardTraining is feedforward with dropout applied to the hidden
+// layers. Dropout is never applied to the output layer.
+func (net *Network) feedforwardTraining(input []float64) []float64 {
+	output := input
+	for i, layer := range net.Layers {
+		output = layer.feedforward(output)
+		if i < len(net.Layers)-1 {
+			layer.applyDropout(net.rand())
+			output = layer.value
+		}
+	}
+	return output
+}
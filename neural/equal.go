@@ -0,0 +1,67 @@
+package neural
+
+import (
+	"math"
+	"reflect"
+)
+
+// Equal reports whether the two networks have the same layers: the same
+// shapes and activations, and weights, biases and batch normalization
+// parameters that differ by no more than tolerance. Training settings like
+// the loss and optimizer aren't compared.
+func (net *Network) Equal(other *Network, tolerance float64) bool {
+	if len(net.Layers) != len(other.Layers) {
+		return false
+	}
+	for i, layer := range net.Layers {
+		if !layer.equal(other.Layers[i], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+func (layer *Layer) equal(other *Layer, tolerance float64) bool {
+	if len(layer.Weight) != len(other.Weight) || (layer.Bias == nil) != (other.Bias == nil) ||
+		(layer.BatchNorm == nil) != (other.BatchNorm == nil) ||
+		!reflect.DeepEqual(layer.activation(), other.activation()) {
+		return false
+	}
+	for i, weight := range layer.Weight {
+		if !weightsEqual(weight, other.Weight[i], tolerance) {
+			return false
+		}
+	}
+	if !weightsEqual(layer.Bias, other.Bias, tolerance) {
+		return false
+	}
+	if bn, obn := layer.BatchNorm, other.BatchNorm; bn != nil {
+		return floatsEqual(bn.Gamma, obn.Gamma, tolerance) && floatsEqual(bn.Beta, obn.Beta, tolerance) &&
+			floatsEqual(bn.Mean, obn.Mean, tolerance) && floatsEqual(bn.Variance, obn.Variance, tolerance)
+	}
+	return true
+}
+
+func weightsEqual(a []Float, b []Float, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !(math.Abs(float64(a[i])-float64(b[i])) <= tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+func floatsEqual(a []float64, b []float64, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !(math.Abs(a[i]-b[i]) <= tolerance) {
+			return false
+		}
+	}
+	return true
+}
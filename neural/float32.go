@@ -0,0 +1,11 @@
+//go:build float32
+
+package neural
+
+// Float is the type of every layer's weights and biases. This build uses
+// float32 to halve a network's memory footprint. Only the stored weights
+// and biases change: inputs and outputs are still []float64, and
+// activations and gradients are still computed in float64.
+type Float = float32
+
+const floatBits = 32
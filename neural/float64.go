@@ -0,0 +1,11 @@
+//go:build !float32
+
+package neural
+
+// Float is the type of every layer's weights and biases. Building with
+// -tags float32 makes it float32, halving a network's memory footprint at
+// some cost in precision. Inputs, outputs, activations and gradients are
+// float64 either way.
+type Float = float64
+
+const floatBits = 64
@@ -0,0 +1,151 @@
+package neural
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// SaveGob writes the network in encoding/gob format, which is much more
+// compact and faster to read than Save's JSON. The stream starts with the
+// SchemaVersion.
+func (net *Network) SaveGob(w io.Writer) error {
+	name, params, err := losses.marshal(net.loss())
+	if err != nil {
+		return err
+	}
+	saved := &gobNetwork{Layers: net.Layers, Loss: name, LossParams: params, WeightDecay: net.WeightDecay}
+	if net.Optimizer != nil {
+		if saved.Optimizer, params, err = optimizers.marshal(net.Optimizer); err != nil {
+			return err
+		}
+		saved.OptimizerParams = params
+	}
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(SchemaVersion); err != nil {
+		return err
+	}
+	return enc.Encode(saved)
+}
+
+type gobNetwork struct {
+	Layers          []*Layer
+	Loss            string
+	LossParams      []byte
+	Optimizer       string
+	OptimizerParams []byte
+	WeightDecay     float64
+}
+
+// gobNetworkV1 is how version 1 streams stored the network, with the loss
+// as a number.
+type gobNetworkV1 struct {
+	Layers     []*Layer
+	Loss       int
+	HuberDelta float64
+}
+
+func LoadNetworkGob(r io.Reader) (*Network, error) {
+	dec := gob.NewDecoder(r)
+	var version int
+	if err := dec.Decode(&version); err != nil {
+		return nil, fmt.Errorf("neural: decoding network: %v", err)
+	}
+	net := new(Network)
+	switch version {
+	case 1:
+		var saved gobNetworkV1
+		if err := dec.Decode(&saved); err != nil {
+			return nil, fmt.Errorf("neural: decoding network: %v", err)
+		}
+		loss, err := legacyLoss(saved.Loss, saved.HuberDelta)
+		if err != nil {
+			return nil, err
+		}
+		net.Layers, net.Loss = saved.Layers, loss
+	case SchemaVersion:
+		var saved gobNetwork
+		if err := dec.Decode(&saved); err != nil {
+			return nil, fmt.Errorf("neural: decoding network: %v", err)
+		}
+		loss, err := unmarshalLoss(saved.Loss, saved.LossParams)
+		if err != nil {
+			return nil, err
+		}
+		opt, err := unmarshalOptimizer(saved.Optimizer, saved.OptimizerParams)
+		if err != nil {
+			return nil, err
+		}
+		net.Layers, net.Loss, net.Optimizer, net.WeightDecay = saved.Layers, loss, opt, saved.WeightDecay
+	default:
+		return nil, fmt.Errorf("neural: decoding network: unsupported schema version %d (this package reads up to version %d)",
+			version, SchemaVersion)
+	}
+	if err := net.initialize(); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+// GobEncode stores the activation by its registered name, as MarshalJSON
+// does, since gob can't encode activations without fields.
+func (layer *Layer) GobEncode() ([]byte, error) {
+	name, params, err := marshalActivation(layer.activation())
+	if err != nil {
+		return nil, err
+	}
+	plain := *(*layerPlain)(layer)
+	plain.Activation = nil
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if err = enc.Encode(&plain); err != nil {
+		return nil, err
+	}
+	if err = enc.Encode(name); err != nil {
+		return nil, err
+	}
+	if err = enc.Encode([]byte(params)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (layer *Layer) GobDecode(data []byte) error {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	var name string
+	var params []byte
+	if err := dec.Decode((*layerPlain)(layer)); err != nil {
+		return err
+	}
+	if err := dec.Decode(&name); err != nil {
+		return err
+	}
+	if err := dec.Decode(&params); err != nil {
+		return err
+	}
+	activation, err := unmarshalActivation(name, params)
+	layer.Activation = activation
+	return err
+}
+
+// MarshalBinary encodes the network as SaveGob does, so that it can be
+// stored as a blob, or as part of a larger gob-encoded value.
+func (net *Network) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := net.SaveGob(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary replaces the network with one decoded from data
+// written by MarshalBinary.
+func (net *Network) UnmarshalBinary(data []byte) error {
+	loaded, err := LoadNetworkGob(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	*net = *loaded
+	return nil
+}
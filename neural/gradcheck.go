@@ -0,0 +1,52 @@
+package neural
+
+import "math"
+
+// GradientCheck compares the gradient computed by backpropagation on one
+// example against the central difference (cost(w+eps)-cost(w-eps))/(2*eps)
+// for every weight and bias, and returns the largest relative error. Values
+// around 1e-7 or below mean backpropagation agrees with the cost; anything
+// near 1 means it doesn't. The network is left unchanged.
+func GradientCheck(net *Network, input []float64, expected []float64, epsilon float64) float64 {
+	net.zeroGradients()
+	net.feedforward(input)
+	net.backward(input, expected, 1)
+
+	cost := func() float64 {
+		return net.cost(net.feedforward(input), expected)
+	}
+	numeric := func(param *Float) float64 {
+		orig := *param
+		*param = orig + Float(epsilon)
+		plus := cost()
+		*param = orig - Float(epsilon)
+		minus := cost()
+		*param = orig
+		return (plus - minus) / (2 * epsilon)
+	}
+
+	worst := 0.0
+	for _, layer := range net.Layers {
+		grad := layer.gradient()
+		for i, weight := range layer.Weight {
+			for j := range weight {
+				worst = math.Max(worst, relativeError(grad.Weight[i][j], numeric(&weight[j])))
+			}
+			if layer.Bias != nil {
+				worst = math.Max(worst, relativeError(grad.Bias[i], numeric(&layer.Bias[i])))
+			}
+		}
+	}
+	return worst
+}
+
+// relativeError compares two gradients relative to the larger of them,
+// treating both as zero when they're smaller than finite differences can
+// resolve.
+func relativeError(analytic float64, numeric float64) float64 {
+	scale := math.Max(math.Abs(analytic), math.Abs(numeric))
+	if scale < 1e-8 {
+		return 0
+	}
+	return math.Abs(analytic-numeric) / scale
+}
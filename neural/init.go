@@ -0,0 +1,71 @@
+package neural
+
+import (
+	"math"
+	"math/rand"
+)
+
+// InitStrategy selects how a new layer's weights are drawn.
+// The zero value is Uniform.
+type InitStrategy int
+
+const (
+	// Uniform draws from U(-1, 1).
+	Uniform InitStrategy = iota
+	// Xavier (Glorot) draws from U(-sqrt(6/(fanIn+fanOut)), +sqrt(...)),
+	// which suits sigmoid and tanh layers.
+	Xavier
+	// He draws from N(0, sqrt(2/fanIn)), which suits ReLU layers.
+	He
+	// Recommended uses RecommendedInit for each layer's activation.
+	Recommended
+)
+
+// RecommendedInit is the strategy that keeps a layer with the given
+// activation from saturating or dying at the start of training: He for
+// ReLU and its relatives, and Xavier for everything else.
+func RecommendedInit(activation Activation) InitStrategy {
+	switch activation.(type) {
+	case ReLU, LeakyReLU, Softplus, ELU:
+		return He
+	}
+	return Xavier
+}
+
+func (strategy InitStrategy) weight(rng *rand.Rand, fanIn int, fanOut int) float64 {
+	switch strategy {
+	case Xavier:
+		limit := math.Sqrt(6.0 / float64(fanIn+fanOut))
+		return (rng.Float64()*2.0 - 1.0) * limit
+	case He:
+		return rng.NormFloat64() * math.Sqrt(2.0/float64(fanIn))
+	}
+	return randomWeight(rng)
+}
+
+func NewNetworkWithInit(inputs int, hiddens int, outputs int, init InitStrategy) *Network {
+	return newDeepNetwork(inputs, []int{hiddens}, outputs, init, newRand(), Sigmoid{}, Sigmoid{}, true)
+}
+
+// NewNetworkSeeded initializes the weights from its own generator seeded
+// with seed, and keeps the generator as the network's Rand, so the same
+// seed always produces the same network, which trains the same way.
+func NewNetworkSeeded(inputs int, hiddens int, outputs int, seed int64) *Network {
+	rng := rand.New(rand.NewSource(seed))
+	net := newDeepNetwork(inputs, []int{hiddens}, outputs, Uniform, rng, Sigmoid{}, Sigmoid{}, true)
+	net.Rand = rng
+	return net
+}
+
+// SetBiases sets every bias in the network to value, in place of the
+// random draw from U(-1, 1) the constructors give them whatever the
+// InitStrategy. Zero is the usual choice; a small positive value like 0.01
+// keeps ReLU units from starting out dead. Layers without biases are left
+// without.
+func (net *Network) SetBiases(value float64) {
+	for _, layer := range net.Layers {
+		for i := range layer.Bias {
+			layer.Bias[i] = Float(value)
+		}
+	}
+}
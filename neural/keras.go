@@ -0,0 +1,171 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// kerasActivations maps the names of Keras's built-in activations to
+// their equivalents here.
+var kerasActivations = map[string]Activation{
+	"linear":     Linear{},
+	"sigmoid":    Sigmoid{},
+	"tanh":       Tanh{},
+	"relu":       ReLU{},
+	"leaky_relu": LeakyReLU{Alpha: 0.2},
+	"softplus":   Softplus{},
+	"elu":        ELU{},
+	"softmax":    Softmax{},
+}
+
+// kerasModel is a Keras model saved alongside its weights, as written by
+//
+//	json.dump({"model": json.loads(model.to_json()),
+//	           "weights": [w.tolist() for w in model.get_weights()]}, f)
+type kerasModel struct {
+	Model struct {
+		ClassName string          `json:"class_name"`
+		Config    json.RawMessage `json:"config"`
+	} `json:"model"`
+	Weights []json.RawMessage `json:"weights"`
+}
+
+type kerasLayer struct {
+	ClassName string `json:"class_name"`
+	Config    struct {
+		Name       string  `json:"name"`
+		Units      int     `json:"units"`
+		Activation string  `json:"activation"`
+		UseBias    *bool   `json:"use_bias"`
+		Rate       float64 `json:"rate"`
+	} `json:"config"`
+}
+
+// LoadKeras builds a network from a sequential Keras model of Dense
+// layers, read as a JSON object holding the model's to_json() description
+// under "model" and its get_weights() arrays under "weights". Dropout
+// layers set the Dropout of the layer before them, and InputLayers are
+// skipped; any other kind of layer is an error, as is an activation with
+// no equivalent here.
+func LoadKeras(r io.Reader) (*Network, error) {
+	var model kerasModel
+	if err := json.NewDecoder(r).Decode(&model); err != nil {
+		return nil, fmt.Errorf("neural: decoding Keras model: %v", err)
+	}
+	if model.Model.ClassName != "Sequential" {
+		return nil, fmt.Errorf("neural: decoding Keras model: unsupported model class %q", model.Model.ClassName)
+	}
+	layers, err := kerasLayers(model.Model.Config)
+	if err != nil {
+		return nil, fmt.Errorf("neural: decoding Keras model: %v", err)
+	}
+	net := new(Network)
+	weights := model.Weights
+	for _, kl := range layers {
+		switch kl.ClassName {
+		case "InputLayer":
+		case "Dropout":
+			if len(net.Layers) == 0 {
+				return nil, fmt.Errorf("neural: decoding Keras model: dropout on the inputs isn't supported")
+			}
+			net.outputLayer().Dropout = kl.Config.Rate
+		case "Dense":
+			layer, err := kerasDense(kl, &weights)
+			if err != nil {
+				return nil, fmt.Errorf("neural: decoding Keras model: layer %q: %v", kl.Config.Name, err)
+			}
+			if len(net.Layers) > 0 && layer.inputs() != len(net.outputLayer().Weight) {
+				return nil, fmt.Errorf("neural: decoding Keras model: layer %q has %d inputs, previous layer has %d units",
+					kl.Config.Name, layer.inputs(), len(net.outputLayer().Weight))
+			}
+			net.Layers = append(net.Layers, layer)
+		default:
+			return nil, fmt.Errorf("neural: decoding Keras model: unsupported layer class %q", kl.ClassName)
+		}
+	}
+	if len(net.Layers) == 0 {
+		return nil, fmt.Errorf("neural: decoding Keras model: no Dense layers")
+	}
+	if len(weights) > 0 {
+		return nil, fmt.Errorf("neural: decoding Keras model: %d weight arrays left over", len(weights))
+	}
+	if err := net.initialize(); err != nil {
+		return nil, err
+	}
+	return net, nil
+}
+
+// kerasLayers decodes a Sequential model's layers, which newer versions of
+// Keras keep under "layers" in its config and older ones use as the config
+// itself.
+func kerasLayers(config json.RawMessage) ([]kerasLayer, error) {
+	var layers []kerasLayer
+	if err := json.Unmarshal(config, &layers); err == nil {
+		return layers, nil
+	}
+	var wrapped struct {
+		Layers []kerasLayer `json:"layers"`
+	}
+	err := json.Unmarshal(config, &wrapped)
+	return wrapped.Layers, err
+}
+
+// kerasDense builds a layer from a Dense layer, taking its kernel, which
+// Keras stores with a row per input, and its bias from the front of
+// weights.
+func kerasDense(kl kerasLayer, weights *[]json.RawMessage) (*Layer, error) {
+	activation, ok := kerasActivations[kl.Config.Activation]
+	if !ok {
+		return nil, fmt.Errorf("unsupported activation %q", kl.Config.Activation)
+	}
+	useBias := kl.Config.UseBias == nil || *kl.Config.UseBias
+	next := func(v interface{}) error {
+		if len(*weights) == 0 {
+			return fmt.Errorf("missing weights")
+		}
+		data := (*weights)[0]
+		*weights = (*weights)[1:]
+		return json.Unmarshal(data, v)
+	}
+	var kernel [][]float64
+	if err := next(&kernel); err != nil {
+		return nil, err
+	}
+	if len(kernel) == 0 {
+		return nil, fmt.Errorf("empty kernel")
+	}
+	units := len(kernel[0])
+	if units == 0 {
+		return nil, fmt.Errorf("kernel has no units")
+	}
+	if kl.Config.Units != 0 && units != kl.Config.Units {
+		return nil, fmt.Errorf("kernel has %d units, layer has %d", units, kl.Config.Units)
+	}
+	layer := &Layer{Weight: make([][]Float, units), Activation: activation}
+	for i := range layer.Weight {
+		layer.Weight[i] = make([]Float, len(kernel))
+	}
+	for j, row := range kernel {
+		if len(row) != units {
+			return nil, fmt.Errorf("kernel row %d has %d units, want %d", j, len(row), units)
+		}
+		for i, w := range row {
+			layer.Weight[i][j] = Float(w)
+		}
+	}
+	if useBias {
+		var bias []float64
+		if err := next(&bias); err != nil {
+			return nil, err
+		}
+		if len(bias) != units {
+			return nil, fmt.Errorf("bias has %d units, want %d", len(bias), units)
+		}
+		layer.Bias = make([]Float, units)
+		for i, b := range bias {
+			layer.Bias[i] = Float(b)
+		}
+	}
+	return layer, nil
+}
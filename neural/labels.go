@@ -0,0 +1,119 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+)
+
+// OneHot returns a vector of numClasses zeros with a 1 at label. It panics
+// if label isn't in [0, numClasses).
+func OneHot(label int, numClasses int) []float64 {
+	if label < 0 || label >= numClasses {
+		panic(fmt.Sprintf("neural: label %d out of range for %d classes", label, numClasses))
+	}
+	v := make([]float64, numClasses)
+	v[label] = 1
+	return v
+}
+
+// OneHotBatch one-hot encodes each of labels.
+func OneHotBatch(labels []int, numClasses int) [][]float64 {
+	vs := make([][]float64, len(labels))
+	for i, label := range labels {
+		vs[i] = OneHot(label, numClasses)
+	}
+	return vs
+}
+
+// labelTargets one-hot encodes labels as expected outputs for net, where
+// a single output is the probability of class 1, as in Accuracy.
+func (net *Network) labelTargets(labels []int) [][]float64 {
+	targets := OneHotBatch(labels, net.numClasses())
+	if len(net.outputLayer().Weight) == 1 {
+		for i, target := range targets {
+			targets[i] = target[1:]
+		}
+	}
+	return targets
+}
+
+// TrainDatasetLabels is TrainDataset for a dataset of class labels, each
+// standing for the one-hot expected output of its class; for a network
+// with a single output, the labels are 0 and 1. It panics on a label the
+// network has no output for.
+func (net *Network) TrainDatasetLabels(inputs [][]float64, labels []int, epochs int, schedule Schedule, lambda float64) []float64 {
+	return net.TrainDataset(inputs, net.labelTargets(labels), epochs, schedule, lambda)
+}
+
+// AccuracyLabels is Accuracy against class labels rather than expected
+// outputs: the fraction of inputs whose predicted class is their label.
+func AccuracyLabels(net *Network, inputs [][]float64, labels []int) float64 {
+	if len(inputs) == 0 {
+		return 0
+	}
+	correct, scratch := 0, net.newScratch()
+	for i, input := range inputs {
+		if class(net.forward(input, scratch)) == labels[i] {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(inputs))
+}
+
+// SmoothLabels returns a copy of the target vector expected with label
+// smoothing eps: each target t becomes (1-eps)*t + eps/K for K classes,
+// so a one-hot 1 becomes 1-eps+eps/K and a 0 becomes eps/K. A single
+// output is treated as the probability of the second of two classes. It
+// panics if eps isn't in [0, 1].
+func SmoothLabels(expected []float64, eps float64) []float64 {
+	if !(eps >= 0 && eps <= 1) {
+		panic(fmt.Sprintf("neural: label smoothing %v out of range [0, 1]", eps))
+	}
+	k := float64(len(expected))
+	if len(expected) == 1 {
+		k = 2
+	}
+	smoothed := make([]float64, len(expected))
+	for i, t := range expected {
+		smoothed[i] = (1-eps)*t + eps/k
+	}
+	return smoothed
+}
+
+// SmoothLabelsBatch applies SmoothLabels to each of expecteds.
+func SmoothLabelsBatch(expecteds [][]float64, eps float64) [][]float64 {
+	smoothed := make([][]float64, len(expecteds))
+	for i, expected := range expecteds {
+		smoothed[i] = SmoothLabels(expected, eps)
+	}
+	return smoothed
+}
+
+// ArgMax is the index of the largest value in v; the first one, if
+// several are equal, so ties always go the same way. NaNs count as
+// smaller than any number. It's -1 if v is empty.
+func ArgMax(v []float64) int {
+	if len(v) == 0 {
+		return -1
+	}
+	best := 0
+	for i, value := range v {
+		if ahead(value, i, v[best], best) {
+			best = i
+		}
+	}
+	return best
+}
+
+// ahead reports whether ArgMax ranks a, at index i, ahead of b, at index
+// j: larger numbers first, then NaNs, with ties going to the lower index.
+func ahead(a float64, i int, b float64, j int) bool {
+	aNaN, bNaN := math.IsNaN(a), math.IsNaN(b)
+	switch {
+	case aNaN != bNaN:
+		return bNaN
+	case !aNaN && a != b:
+		return a > b
+	}
+	return i < j
+}
@@ -0,0 +1,434 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// Loss is the error measure Train minimizes at the output layer. Cost is
+// the loss on one example, and Gradient its partial derivatives with
+// respect to each of the results. A nil Loss is SquaredError.
+type Loss interface {
+	Cost(result []float64, expected []float64) float64
+	Gradient(result []float64, expected []float64) []float64
+}
+
+// SquaredError is half the summed squared error.
+type SquaredError struct{}
+
+func (SquaredError) Cost(result []float64, expected []float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(result); i++ {
+		sum += (expected[i] - result[i]) * (expected[i] - result[i])
+	}
+	return sum / 2
+}
+
+func (SquaredError) Gradient(result []float64, expected []float64) []float64 {
+	grad := make([]float64, len(result))
+	for i := range grad {
+		grad[i] = result[i] - expected[i]
+	}
+	return grad
+}
+
+// CrossEntropy treats each output as a probability, and is the summed
+// CrossEntropyError. It pairs with a Sigmoid output layer (independent
+// labels) or a Softmax one (mutually exclusive classes, for which the
+// network measures the categorical cross-entropy instead); for both, Train
+// uses the exact gradient at the output sums, result - expected. Other
+// activations are trained through the chain rule on Gradient.
+//
+// Results are clamped to [Epsilon, 1-Epsilon] before their logarithms are
+// taken, so that a saturated output costs a lot rather than Inf. A larger
+// Epsilon is safer but understates the cost of confident mistakes. Zero
+// means 1e-15.
+type CrossEntropy struct {
+	Epsilon float64 `json:",omitempty"`
+}
+
+func (loss CrossEntropy) epsilon() float64 {
+	if loss.Epsilon > 0 {
+		return loss.Epsilon
+	}
+	return probabilityEpsilon
+}
+
+func (loss CrossEntropy) Cost(result []float64, expected []float64) float64 {
+	return crossEntropy(result, expected, loss.epsilon())
+}
+
+func (loss CrossEntropy) Gradient(result []float64, expected []float64) []float64 {
+	eps := loss.epsilon()
+	grad := make([]float64, len(result))
+	for i := range grad {
+		p := clampProbability(result[i], eps)
+		grad[i] = (result[i] - expected[i]) / (p * (1.0 - p))
+	}
+	return grad
+}
+
+// Huber is squared error for residuals up to Delta and absolute error
+// beyond it, so outliers pull on the weights no harder than a residual of
+// Delta would. A Delta of 0 means 1.
+type Huber struct {
+	Delta float64
+}
+
+func (loss Huber) delta() float64 {
+	if loss.Delta > 0 {
+		return loss.Delta
+	}
+	return 1
+}
+
+func (loss Huber) Cost(result []float64, expected []float64) float64 {
+	return huber(result, expected, loss.delta())
+}
+
+func (loss Huber) Gradient(result []float64, expected []float64) []float64 {
+	delta := loss.delta()
+	grad := make([]float64, len(result))
+	for i := range grad {
+		grad[i] = math.Max(-delta, math.Min(result[i]-expected[i], delta))
+	}
+	return grad
+}
+
+var losses = newRegistry("loss")
+
+func init() {
+	RegisterLoss("SquaredError", SquaredError{})
+	RegisterLoss("CrossEntropy", CrossEntropy{})
+	RegisterLoss("Huber", Huber{})
+}
+
+// RegisterLoss makes a custom loss known to Save and LoadNetwork under the
+// given name. Any exported fields of the loss are saved along with it.
+func RegisterLoss(name string, loss Loss) {
+	losses.register(name, loss)
+}
+
+func (net *Network) loss() Loss {
+	if net.Loss == nil {
+		return SquaredError{}
+	}
+	return net.Loss
+}
+
+func unmarshalLoss(name string, params json.RawMessage) (Loss, error) {
+	if name == "" {
+		return SquaredError{}, nil
+	}
+	loss, err := losses.unmarshal(name, params)
+	if err != nil {
+		return nil, err
+	}
+	return loss.(Loss), nil
+}
+
+// probabilityEpsilon keeps probabilities away from 0 and 1 so that their
+// logarithms stay finite.
+const probabilityEpsilon = 1e-15
+
+// ClampProbabilities returns a copy of v with each value clamped to
+// [eps, 1-eps], as the log-based losses do before taking logarithms.
+func ClampProbabilities(v []float64, eps float64) []float64 {
+	clamped := make([]float64, len(v))
+	for i, p := range v {
+		clamped[i] = clampProbability(p, eps)
+	}
+	return clamped
+}
+
+func clampProbability(p float64, eps float64) float64 {
+	return math.Min(math.Max(p, eps), 1.0-eps)
+}
+
+func MeanSquaredError(result []float64, expected []float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(result); i++ {
+		sum += math.Pow(float64(expected[i]-result[i]), 2)
+	}
+	return float64(sum) / float64(len(result))
+}
+
+// MeanAbsoluteError is the mean of |expected - result| over the outputs.
+func MeanAbsoluteError(result []float64, expected []float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(result); i++ {
+		sum += math.Abs(expected[i] - result[i])
+	}
+	return sum / float64(len(result))
+}
+
+// RSquared is the coefficient of determination 1 - SS_res/SS_tot of
+// results against expecteds, computed for each output separately and then
+// averaged. An output whose expected values are all the same has no
+// variance to explain; it scores 1 if it's predicted exactly, and 0
+// otherwise.
+func RSquared(results [][]float64, expecteds [][]float64) float64 {
+	if len(expecteds) == 0 {
+		return 0
+	}
+	total := 0.0
+	outputs := len(expecteds[0])
+	for j := 0; j < outputs; j++ {
+		mean := 0.0
+		for _, expected := range expecteds {
+			mean += expected[j]
+		}
+		mean /= float64(len(expecteds))
+		ssRes, ssTot := 0.0, 0.0
+		for i, expected := range expecteds {
+			ssRes += (expected[j] - results[i][j]) * (expected[j] - results[i][j])
+			ssTot += (expected[j] - mean) * (expected[j] - mean)
+		}
+		switch {
+		case ssTot > 0:
+			total += 1 - ssRes/ssTot
+		case ssRes == 0:
+			total++
+		}
+	}
+	return total / float64(outputs)
+}
+
+// HuberLoss is the mean Huber loss of result against expected: half the
+// squared residual where it's at most delta, and delta*(|r| - delta/2)
+// where it's larger.
+func HuberLoss(result []float64, expected []float64, delta float64) float64 {
+	return huber(result, expected, delta) / float64(len(result))
+}
+
+func huber(result []float64, expected []float64, delta float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(result); i++ {
+		r := math.Abs(expected[i] - result[i])
+		if r <= delta {
+			sum += r * r / 2
+		} else {
+			sum += delta * (r - delta/2)
+		}
+	}
+	return sum
+}
+
+// CrossEntropyError is the cross-entropy of result against expected,
+// summed over the outputs. Results are clamped to within 1e-15 of 0 and
+// 1.
+func CrossEntropyError(result []float64, expected []float64) float64 {
+	return crossEntropy(result, expected, probabilityEpsilon)
+}
+
+func crossEntropy(result []float64, expected []float64, eps float64) float64 {
+	sum := 0.0
+	for i := 0; i < len(result); i++ {
+		p := clampProbability(result[i], eps)
+		sum -= expected[i]*math.Log(p) + (1.0-expected[i])*math.Log(1.0-p)
+	}
+	return sum
+}
+
+// cost is the loss on one example whose gradient Train follows: the
+// network's Loss, except that cross-entropy over a Softmax output is
+// categorical.
+func (net *Network) cost(result []float64, expected []float64) float64 {
+	loss := net.loss()
+	if ce, ok := loss.(CrossEntropy); ok {
+		if _, ok := net.outputLayer().activation().(Softmax); ok {
+			sum, eps := 0.0, ce.epsilon()
+			for i := 0; i < len(result); i++ {
+				sum -= expected[i] * math.Log(clampProbability(result[i], eps))
+			}
+			return sum
+		}
+	}
+	return loss.Cost(result, expected)
+}
+
+// RegularizedCost is the loss on one example plus the L2 penalty
+// lambda/2*sum(w^2) over every weight, as minimized by TrainBatch.
+func (net *Network) RegularizedCost(result []float64, expected []float64, lambda float64) float64 {
+	return net.cost(result, expected) + lambda/2*net.sumSquaredWeights()
+}
+
+// DatasetCost is the mean loss over a dataset plus the L2 penalty
+// lambda/2*sum(w^2), which is counted once, rather than once per example as
+// summing RegularizedCost would. It's the cost TrainBatch minimizes on a
+// batch of these examples.
+func (net *Network) DatasetCost(inputs [][]float64, expecteds [][]float64, lambda float64) float64 {
+	penalty := lambda / 2 * net.sumSquaredWeights()
+	if len(inputs) == 0 {
+		return penalty
+	}
+	total, scratch := 0.0, net.newScratch()
+	for i, input := range inputs {
+		total += net.cost(net.forward(input, scratch), expecteds[i])
+	}
+	return total/float64(len(inputs)) + penalty
+}
+
+func (net *Network) sumSquaredWeights() float64 {
+	sum := 0.0
+	for _, layer := range net.Layers {
+		for _, weight := range layer.Weight {
+			for _, w := range weight {
+				sum += float64(w) * float64(w)
+			}
+		}
+	}
+	return sum
+}
+
+// class decodes an output or target vector into a class index: the index
+// of its largest value as ArgMax finds it, or for a single node, 1 if it's
+// at least 0.5 and 0 otherwise.
+func class(v []float64) int {
+	if len(v) == 1 {
+		if v[0] >= 0.5 {
+			return 1
+		}
+		return 0
+	}
+	return ArgMax(v)
+}
+
+// numClasses is the number of classes class can return for a network.
+func (net *Network) numClasses() int {
+	if n := len(net.outputLayer().Weight); n > 1 {
+		return n
+	}
+	return 2
+}
+
+// Accuracy is the fraction of inputs whose predicted class matches the
+// class of the expected output. Classes are read as the largest output, or
+// for single-output networks, as the output thresholded at 0.5.
+func Accuracy(net *Network, inputs [][]float64, expecteds [][]float64) float64 {
+	if len(inputs) == 0 {
+		return 0
+	}
+	correct, scratch := 0, net.newScratch()
+	for i, input := range inputs {
+		if class(net.forward(input, scratch)) == class(expecteds[i]) {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(inputs))
+}
+
+// TopKAccuracy is the fraction of inputs whose expected class is among
+// the k classes with the highest outputs, so that it's Accuracy for a k of
+// 1. Classes are ranked as ArgMax ranks them, with ties going to the
+// lower class and NaNs behind every number. It panics if k is less than 1.
+func TopKAccuracy(net *Network, inputs [][]float64, expecteds [][]float64, k int) float64 {
+	if k < 1 {
+		panic(fmt.Sprintf("neural: can't take the top %d classes", k))
+	}
+	if len(inputs) == 0 {
+		return 0
+	}
+	correct, scratch := 0, net.newScratch()
+	for i, input := range inputs {
+		output, c := net.forward(input, scratch), class(expecteds[i])
+		if len(output) == 1 {
+			// There are only two classes, so any k past 1 takes both.
+			if k > 1 || class(output) == c {
+				correct++
+			}
+			continue
+		}
+		// rank is the number of classes ahead of the expected one.
+		rank := 0
+		for j, score := range output {
+			if ahead(score, j, output[c], c) {
+				rank++
+			}
+		}
+		if rank < k {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(inputs))
+}
+
+// ConfusionMatrix counts the inputs by predicted and actual class, so that
+// m[p][a] is the number of inputs of class a that were predicted as p.
+// Classes are read as in Accuracy.
+func ConfusionMatrix(net *Network, inputs [][]float64, expecteds [][]float64) [][]int {
+	m := make([][]int, net.numClasses())
+	for i := range m {
+		m[i] = make([]int, len(m))
+	}
+	scratch := net.newScratch()
+	for i, input := range inputs {
+		m[class(net.forward(input, scratch))][class(expecteds[i])]++
+	}
+	return m
+}
+
+// PrecisionRecallF1 computes each class's precision, recall and F1 score
+// from a confusion matrix laid out as ConfusionMatrix's. A metric whose
+// denominator is zero, like the precision of a class that's never
+// predicted or the F1 of a class with zero precision and recall, is
+// defined as 0.
+func PrecisionRecallF1(cm [][]int) (precision, recall, f1 []float64) {
+	precision = make([]float64, len(cm))
+	recall = make([]float64, len(cm))
+	f1 = make([]float64, len(cm))
+	for c := range cm {
+		predicted, actual := 0, 0
+		for other := range cm {
+			predicted += cm[c][other]
+			actual += cm[other][c]
+		}
+		precision[c] = ratio(cm[c][c], predicted)
+		recall[c] = ratio(cm[c][c], actual)
+		if sum := precision[c] + recall[c]; sum > 0 {
+			f1[c] = 2 * precision[c] * recall[c] / sum
+		}
+	}
+	return
+}
+
+// MacroAverage is the unweighted mean of a per-class metric, such as one
+// returned by PrecisionRecallF1, so every class counts equally however
+// rare it is.
+func MacroAverage(metric []float64) float64 {
+	if len(metric) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, m := range metric {
+		sum += m
+	}
+	return sum / float64(len(metric))
+}
+
+// MicroPrecisionRecallF1 computes precision, recall and F1 over the
+// predictions of every class pooled together, weighting classes by their
+// size. When each input has exactly one class, as in ConfusionMatrix, all
+// three equal the accuracy.
+func MicroPrecisionRecallF1(cm [][]int) (precision, recall, f1 float64) {
+	correct, total := 0, 0
+	for p := range cm {
+		for a, n := range cm[p] {
+			total += n
+			if p == a {
+				correct += n
+			}
+		}
+	}
+	precision = ratio(correct, total)
+	return precision, precision, precision
+}
+
+// ratio is n/d, or 0 if d is 0.
+func ratio(n int, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
@@ -0,0 +1,64 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Mixup blends two examples into a new one, lam*first + (1-lam)*second
+// for both the inputs and the expected outputs, with lam drawn from
+// Beta(alpha, alpha) using the package's generator. Small alphas mostly
+// keep one example or the other; an alpha of 1 mixes uniformly. It panics
+// if alpha isn't positive or the examples' lengths differ.
+func Mixup(in1 []float64, exp1 []float64, in2 []float64, exp2 []float64, alpha float64) (input []float64, expected []float64) {
+	return mixup(in1, exp1, in2, exp2, alpha, random)
+}
+
+func mixup(in1 []float64, exp1 []float64, in2 []float64, exp2 []float64, alpha float64, rng *rand.Rand) (input []float64, expected []float64) {
+	if !(alpha > 0) {
+		panic(fmt.Errorf("neural: mixup alpha must be positive, got %v", alpha))
+	}
+	if len(in1) != len(in2) || len(exp1) != len(exp2) {
+		panic(fmt.Errorf("neural: can't mix examples of different lengths"))
+	}
+	x, y := randomGamma(alpha, rng), randomGamma(alpha, rng)
+	if x+y == 0 {
+		// Both underflowed, as they can for tiny alphas, whose mixes are
+		// almost always one example or the other.
+		x = float64(rng.Intn(2))
+		y = 1 - x
+	}
+	lam := x / (x + y)
+	return blend(in1, in2, lam), blend(exp1, exp2, lam)
+}
+
+func blend(a []float64, b []float64, lam float64) []float64 {
+	out := make([]float64, len(a))
+	for i := range a {
+		out[i] = lam*a[i] + (1-lam)*b[i]
+	}
+	return out
+}
+
+// randomGamma draws from the Gamma(shape, 1) distribution by Marsaglia
+// and Tsang's method, boosting shapes below 1 as they suggest.
+func randomGamma(shape float64, rng *rand.Rand) float64 {
+	if shape < 1 {
+		return randomGamma(shape+1, rng) * math.Pow(rng.Float64(), 1/shape)
+	}
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if math.Log(u) < x*x/2+d-d*v+d*math.Log(v) {
+			return d * v
+		}
+	}
+}
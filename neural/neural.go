@@ -1,128 +1,1019 @@
 package neural
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"math/rand"
-	"time"
+	"reflect"
+	"strconv"
+	"strings"
 )
 
 type Layer struct {
-	Weight [][]float64
-	Bias   []float64
-	value  []float64
+	Weight [][]Float `json:"weight"`
+	// Bias is nil for layers without biases, which compute a purely
+	// linear map before their activation.
+	Bias       []Float    `json:"bias,omitempty"`
+	Activation Activation `json:"-"`
+	// Dropout is the probability of dropping each of the layer's units
+	// while training. It has no effect on the output layer or on Activate.
+	Dropout   float64    `json:"dropout,omitempty"`
+	BatchNorm *BatchNorm `json:"batchNorm,omitempty"`
+	// Frozen layers pass gradients back to the layers below them, but
+	// training leaves their own parameters unchanged.
+	Frozen bool `json:"frozen,omitempty"`
+	// RateScale multiplies the learning rate for the layer's parameters,
+	// whatever rate training or its schedule uses, so that early layers
+	// can learn more slowly than later ones. Zero means 1.
+	RateScale float64 `json:"rateScale,omitempty"`
+	// sum caches the inputs to the activation from the last feedforward,
+	// and value its outputs. They only serve training's backward pass, so
+	// they aren't saved, and are allocated by the first feedforward for
+	// layers built without a constructor or loader.
+	sum        []float64
+	value      []float64
+	normalized []float64
+	grad       *gradient
+	// state is the optimizer state for the layer's weights and biases,
+	// and stateType the type of optimizer it belongs to.
+	state     []float64
+	stateType reflect.Type
+	mask      []float64
+	masked    bool
 }
 
 type Network struct {
-	Hidden *Layer
-	Output *Layer
+	Layers    []*Layer
+	Loss      Loss
+	Optimizer Optimizer
+	// WeightDecay shrinks every weight by rate*WeightDecay*w after each
+	// optimizer step. Unlike the lambda of TrainBatch it isn't part of the
+	// gradient, so adaptive optimizers don't rescale it.
+	WeightDecay float64
+	// Rand, if set, is the generator training draws on for dropout and for
+	// shuffling and mixing examples, in place of the package's, so that a
+	// network given its own seeded generator trains the same way every
+	// time. Unlike the package's, it mustn't be shared by networks being
+	// trained at the same time.
+	Rand *rand.Rand
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// rand returns the generator training draws on: Rand, or the package's.
+func (net *Network) rand() *rand.Rand {
+	if net.Rand != nil {
+		return net.Rand
+	}
+	return random
 }
 
-func randomWeight() float64 {
-	return float64(rand.Float64()*2.0 - 1.0)
+func randomWeight(rng *rand.Rand) float64 {
+	return float64(rng.Float64()*2.0 - 1.0)
 }
 
 func (layer *Layer) initialize() {
+	layer.sum = make([]float64, len(layer.Weight))
 	layer.value = make([]float64, len(layer.Weight))
 }
 
-func newLayer(inputs int, nodes int) (layer *Layer) {
+func newLayer(inputs int, nodes int, activation Activation, init InitStrategy, rng *rand.Rand, useBias bool) (layer *Layer) {
+	if init == Recommended {
+		init = RecommendedInit(activation)
+	}
 	layer = new(Layer)
-	layer.Weight = make([][]float64, nodes)
+	layer.Weight = make([][]Float, nodes)
 	for i := 0; i < nodes; i++ {
-		layer.Weight[i] = make([]float64, inputs)
+		layer.Weight[i] = make([]Float, inputs)
 		for j := 0; j < inputs; j++ {
-			layer.Weight[i][j] = randomWeight()
+			layer.Weight[i][j] = Float(init.weight(rng, inputs, nodes))
 		}
 	}
-	layer.Bias = make([]float64, nodes)
-	for i := 0; i < nodes; i++ {
-		layer.Bias[i] = randomWeight()
+	if useBias {
+		layer.Bias = make([]Float, nodes)
+		for i := 0; i < nodes; i++ {
+			layer.Bias[i] = Float(randomWeight(rng))
+		}
 	}
+	layer.Activation = activation
 	layer.initialize()
 	return
 }
 
 func NewNetwork(inputs int, hiddens int, outputs int) (net *Network) {
+	return NewDeepNetwork(inputs, []int{hiddens}, outputs)
+}
+
+// NewDeepNetwork creates a network with a hidden layer for each entry of
+// hiddens. Like every constructor, it panics if any size is less than 1.
+func NewDeepNetwork(inputs int, hiddens []int, outputs int) (net *Network) {
+	return newDeepNetwork(inputs, hiddens, outputs, Uniform, newRand(), Sigmoid{}, Sigmoid{}, true)
+}
+
+// NewNetworkWithActivations creates a network whose hidden layer uses the
+// hidden activation and whose output layer uses output, such as ReLU and
+// Linear for regression or ReLU and Softmax for classification. Each
+// layer's weights are initialized as RecommendedInit suggests for its
+// activation, and a Softmax output gets the CrossEntropy loss it's meant
+// to be trained with.
+func NewNetworkWithActivations(inputs int, hiddens int, outputs int, hidden Activation, output Activation) *Network {
+	net := newDeepNetwork(inputs, []int{hiddens}, outputs, Recommended, newRand(), hidden, output, true)
+	if _, ok := output.(Softmax); ok {
+		net.Loss = CrossEntropy{}
+	}
+	return net
+}
+
+// NewNetworkWithoutBias creates a network whose layers have no biases.
+func NewNetworkWithoutBias(inputs int, hiddens int, outputs int) *Network {
+	return newDeepNetwork(inputs, []int{hiddens}, outputs, Uniform, newRand(), Sigmoid{}, Sigmoid{}, false)
+}
+
+// NewNetworkFromWeights creates a network with one sigmoid hidden layer
+// from weights computed elsewhere, with one row of weights per node. A nil
+// bias gives that layer no biases. The matrices are copied, and an error
+// is returned if their dimensions don't fit together.
+func NewNetworkFromWeights(hiddenW [][]float64, hiddenB []float64, outputW [][]float64, outputB []float64) (*Network, error) {
+	inputs := 0
+	if len(hiddenW) > 0 {
+		inputs = len(hiddenW[0])
+	}
+	hidden, err := layerFromWeights("hidden", hiddenW, hiddenB, inputs)
+	if err != nil {
+		return nil, err
+	}
+	output, err := layerFromWeights("output", outputW, outputB, len(hiddenW))
+	if err != nil {
+		return nil, err
+	}
+	return &Network{Layers: []*Layer{hidden, output}}, nil
+}
+
+func layerFromWeights(name string, weights [][]float64, bias []float64, inputs int) (*Layer, error) {
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("neural: %s layer needs at least 1 node, got 0", name)
+	}
+	if inputs < 1 {
+		return nil, fmt.Errorf("neural: %s layer needs at least 1 input, got %d", name, inputs)
+	}
+	if bias != nil && len(bias) != len(weights) {
+		return nil, fmt.Errorf("neural: %s layer has %d nodes but %d biases", name, len(weights), len(bias))
+	}
+	layer := &Layer{Weight: make([][]Float, len(weights)), Activation: Sigmoid{}}
+	for i, row := range weights {
+		if len(row) != inputs {
+			return nil, fmt.Errorf("neural: %s weight row %d has %d inputs, want %d", name, i, len(row), inputs)
+		}
+		layer.Weight[i] = make([]Float, inputs)
+		for j, w := range row {
+			layer.Weight[i][j] = Float(w)
+		}
+	}
+	if bias != nil {
+		layer.Bias = make([]Float, len(bias))
+		for i, b := range bias {
+			layer.Bias[i] = Float(b)
+		}
+	}
+	layer.initialize()
+	return layer, nil
+}
+
+// newRand returns a generator seeded from the package's, for networks
+// that weren't given a seed of their own.
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(random.Int63()))
+}
+
+func newDeepNetwork(inputs int, hiddens []int, outputs int, init InitStrategy, rng *rand.Rand,
+	hidden Activation, output Activation, useBias bool) (net *Network) {
+	sizes := make([]int, 0, len(hiddens)+2)
+	sizes = append(sizes, inputs)
+	sizes = append(sizes, hiddens...)
+	sizes = append(sizes, outputs)
+	if err := checkSizes(inputs, hiddens, outputs); err != nil {
+		panic(err)
+	}
 	net = new(Network)
-	net.Hidden = newLayer(inputs, hiddens)
-	net.Output = newLayer(hiddens, outputs)
+	net.Layers = make([]*Layer, len(sizes)-1)
+	for i := 0; i < len(net.Layers); i++ {
+		activation := hidden
+		if i == len(net.Layers)-1 {
+			activation = output
+		}
+		net.Layers[i] = newLayer(sizes[i], sizes[i+1], activation, init, rng, useBias)
+	}
 	return
 }
 
-func (layer *Layer) feedforward(input []float64) []float64 {
-	for i := 0; i < len(layer.value); i++ {
-		sum := layer.Bias[i]
+func checkSizes(inputs int, hiddens []int, outputs int) error {
+	if inputs < 1 {
+		return fmt.Errorf("neural: network needs at least 1 input, got %d", inputs)
+	}
+	for i, hidden := range hiddens {
+		if hidden < 1 {
+			return fmt.Errorf("neural: hidden layer %d needs at least 1 node, got %d", i+1, hidden)
+		}
+	}
+	if outputs < 1 {
+		return fmt.Errorf("neural: network needs at least 1 output, got %d", outputs)
+	}
+	return nil
+}
+
+func (net *Network) outputLayer() *Layer {
+	return net.Layers[len(net.Layers)-1]
+}
+
+// layerName labels a layer for display: "Hidden" (or "Hidden1",
+// "Hidden2", ... when there are several) and "Output".
+func (net *Network) layerName(i int) string {
+	switch {
+	case i == len(net.Layers)-1:
+		return "Output"
+	case len(net.Layers) == 2:
+		return "Hidden"
+	}
+	return fmt.Sprintf("Hidden%d", i+1)
+}
+
+func (layer *Layer) weightedSum(input []float64, sums []float64) {
+	for i := 0; i < len(sums); i++ {
+		sum := 0.0
+		if layer.Bias != nil {
+			sum = float64(layer.Bias[i])
+		}
 		for j := 0; j < len(input); j++ {
-			sum += layer.Weight[i][j] * input[j]
+			sum += float64(layer.Weight[i][j]) * input[j]
+		}
+		sums[i] = sum
+	}
+}
+
+// activate applies the layer's activation to its sums in place.
+func (layer *Layer) activate(sums []float64) {
+	applyActivation(layer.activation(), sums)
+}
+
+func applyActivation(activation Activation, sums []float64) {
+	switch activation := activation.(type) {
+	case VectorActivation:
+		activation.ApplyVector(sums, sums)
+	default:
+		for i, sum := range sums {
+			sums[i] = activation.Apply(sum)
 		}
-		layer.value[i] = float64(1.0 / (1.0 + math.Pow(math.E, -float64(sum))))
 	}
+}
+
+func (layer *Layer) feedforward(input []float64) []float64 {
+	if len(layer.value) != len(layer.Weight) {
+		layer.initialize()
+	}
+	layer.weightedSum(input, layer.value)
+	if layer.BatchNorm != nil {
+		layer.normalized = layer.BatchNorm.normalize(layer.value, layer.normalized)
+	}
+	layer.masked = false
+	copy(layer.sum, layer.value)
+	layer.activate(layer.value)
 	return layer.value
 }
 
+// feedforward runs input through every layer and returns the output
+// layer's cached values, which backward relies on. Only training uses it;
+// everything else goes through forward, which leaves the caches alone.
+func (net *Network) feedforward(input []float64) []float64 {
+	output := input
+	for _, layer := range net.Layers {
+		output = layer.feedforward(output)
+	}
+	return output
+}
+
+// InputSize is the number of inputs the network expects.
+func (net *Network) InputSize() int {
+	return len(net.Layers[0].Weight[0])
+}
+
+func (net *Network) checkInput(input []float64) error {
+	if len(input) != net.InputSize() {
+		return fmt.Errorf("neural: got %d inputs, network expects %d", len(input), net.InputSize())
+	}
+	return nil
+}
+
+// Activate runs input through the network and returns the output. It
+// panics if input isn't InputSize long; see ActivateChecked. Activate
+// only reads the network, never touching the values training caches for
+// its backward pass, so a trained network can serve any number of
+// goroutines at once, and scoring between training steps is harmless.
 func (net *Network) Activate(input []float64) (result []float64) {
-	hidden := net.Hidden.feedforward(input)
-	output := net.Output.feedforward(hidden)
-	result = make([]float64, len(output))
-	copy(result, output)
-	return
+	result = make([]float64, len(net.outputLayer().Weight))
+	net.ActivateInto(input, result)
+	return result
+}
+
+// ActivateChecked is like Activate, but returns an error instead of
+// panicking when input is the wrong length.
+func (net *Network) ActivateChecked(input []float64) ([]float64, error) {
+	if err := net.checkInput(input); err != nil {
+		return nil, err
+	}
+	return net.Activate(input), nil
+}
+
+// Predict runs input through the network and returns the index of the
+// largest output, the lowest of any that tie, or for a single output, 1
+// if it's at least 0.5 and 0 otherwise.
+func (net *Network) Predict(input []float64) int {
+	return class(net.Activate(input))
+}
+
+// PredictProba is Activate, for classification code where the outputs are
+// read as class probabilities.
+func (net *Network) PredictProba(input []float64) []float64 {
+	return net.Activate(input)
+}
+
+// PredictWithConfidence is Predict, also returning the probability of the
+// predicted class: its output, or for a single output, the output if the
+// class is 1 and one minus it if the class is 0.
+func (net *Network) PredictWithConfidence(input []float64) (int, float64) {
+	outputs := net.Activate(input)
+	c := class(outputs)
+	return c, classProbabilities(outputs)[c]
+}
+
+// PredictiveEntropy is the entropy, in nats, of the class probabilities
+// for input: 0 when the network is certain, and log(classes) when it
+// can't tell them apart. Outputs that don't sum to 1 are normalized first.
+func (net *Network) PredictiveEntropy(input []float64) float64 {
+	probs := classProbabilities(net.Activate(input))
+	total := 0.0
+	for _, p := range probs {
+		total += p
+	}
+	entropy := 0.0
+	for _, p := range probs {
+		if p > 0 {
+			entropy -= p / total * math.Log(p/total)
+		}
+	}
+	return entropy
+}
+
+// classProbabilities reads outputs as a probability for each class, as
+// class does: a single output is the probability of class 1.
+func classProbabilities(outputs []float64) []float64 {
+	if len(outputs) == 1 {
+		return []float64{1 - outputs[0], outputs[0]}
+	}
+	return outputs
+}
+
+// LayerActivations runs input through the network as far as Layers[layer]
+// and returns a copy of that layer's output, for use as learned features.
+// It panics if input isn't InputSize long or there's no such layer.
+func (net *Network) LayerActivations(input []float64, layer int) []float64 {
+	if err := net.checkInput(input); err != nil {
+		panic(err)
+	}
+	if layer < 0 || layer >= len(net.Layers) {
+		panic(fmt.Sprintf("neural: no layer %d in a network of %d layers", layer, len(net.Layers)))
+	}
+	scratch := net.newScratch()
+	output := input
+	for i := 0; i <= layer; i++ {
+		net.Layers[i].forward(output, scratch[i])
+		output = scratch[i]
+	}
+	return output
+}
+
+// HiddenActivations is LayerActivations for the last hidden layer.
+func (net *Network) HiddenActivations(input []float64) []float64 {
+	return net.LayerActivations(input, len(net.Layers)-2)
+}
+
+// gradient holds the partial derivatives of the cost with respect to a
+// layer's weights and biases, accumulated over one or more examples.
+// Gamma and Beta are only allocated for layers with batch normalization.
+type gradient struct {
+	Weight [][]float64
+	Bias   []float64 `json:",omitempty"`
+	Gamma  []float64 `json:",omitempty"`
+	Beta   []float64 `json:",omitempty"`
+}
+
+func newGradient(layer *Layer) *gradient {
+	grad := &gradient{
+		Weight: make([][]float64, len(layer.Weight)),
+	}
+	if layer.Bias != nil {
+		grad.Bias = make([]float64, len(layer.Bias))
+	}
+	for i, weight := range layer.Weight {
+		grad.Weight[i] = make([]float64, len(weight))
+	}
+	if layer.BatchNorm != nil {
+		grad.Gamma = make([]float64, len(layer.Weight))
+		grad.Beta = make([]float64, len(layer.Weight))
+	}
+	return grad
+}
+
+// each calls f with a pointer to every entry of the gradient.
+func (grad *gradient) each(f func(*float64)) {
+	for _, weight := range grad.Weight {
+		for j := range weight {
+			f(&weight[j])
+		}
+	}
+	for i := range grad.Bias {
+		f(&grad.Bias[i])
+	}
+	for i := range grad.Gamma {
+		f(&grad.Gamma[i])
+		f(&grad.Beta[i])
+	}
+}
+
+// meanAbsWeight is the mean absolute value of the weights' gradients.
+func (grad *gradient) meanAbsWeight() float64 {
+	sum, n := 0.0, 0
+	for _, weight := range grad.Weight {
+		for _, g := range weight {
+			sum += math.Abs(g)
+		}
+		n += len(weight)
+	}
+	return sum / float64(n)
+}
+
+func (grad *gradient) zero() {
+	grad.each(func(g *float64) { *g = 0 })
+}
+
+// gradient returns the layer's gradient buffer, allocating it on first use
+// so that networks used only for inference don't pay for it.
+func (layer *Layer) gradient() *gradient {
+	if layer.grad == nil || (layer.BatchNorm != nil && layer.grad.Gamma == nil) {
+		layer.grad = newGradient(layer)
+	}
+	return layer.grad
+}
+
+// delta scales the error at each node by the activation's derivative,
+// giving the cost that backpropagate distributes over the weights.
+func (layer *Layer) delta(err []float64) []float64 {
+	mask := layer.mask
+	if !layer.masked {
+		mask = nil
+	}
+	return layer.deltaFrom(err, layer.sum, layer.value, mask)
+}
+
+// deltaFrom is delta for the given sums, activated values and dropout
+// mask, which is nil when no units were dropped.
+func (layer *Layer) deltaFrom(err []float64, sums []float64, values []float64, mask []float64) []float64 {
+	activation := layer.activation()
+	if _, ok := activation.(Softmax); ok {
+		return softmaxDelta(err, sums, mask)
+	}
+	bySum, _ := activation.(SumDerivativeActivation)
+	for i := range err {
+		scale := 1.0
+		if mask != nil {
+			if scale = mask[i]; scale == 0 {
+				err[i] = 0
+				continue
+			}
+		}
+		if bySum != nil {
+			err[i] *= scale * bySum.SumDerivative(sums[i])
+		} else {
+			err[i] *= scale * activation.Derivative(values[i]/scale)
+		}
+	}
+	return err
 }
 
-func (layer *Layer) backpropagate(input []float64, err []float64, rate float64, accel float64) (residual []float64) {
+// softmaxDelta is deltaFrom for a Softmax layer, whose outputs each depend
+// on every sum: the full Jacobian-vector product s_i*(g_i - sum_j g_j*s_j).
+// The softmax is recomputed from the sums, since dropout may have zeroed
+// some of the values.
+func softmaxDelta(err []float64, sums []float64, mask []float64) []float64 {
+	s := make([]float64, len(sums))
+	Softmax{}.ApplyVector(sums, s)
+	dot := 0.0
+	for i := range err {
+		if mask != nil {
+			err[i] *= mask[i]
+		}
+		dot += err[i] * s[i]
+	}
+	for i := range err {
+		err[i] = s[i] * (err[i] - dot)
+	}
+	return err
+}
+
+// backpropagate adds the gradient of the cost with respect to the layer's
+// weights to grad, and returns the error at each of the layer's inputs.
+func (layer *Layer) backpropagate(input []float64, delta []float64, grad *gradient) (residual []float64) {
 	residual = make([]float64, len(layer.Weight[0]))
 	for i, weight := range layer.Weight {
-		cost := err[i] * layer.value[i] * (1.0 - layer.value[i])
+		cost := delta[i]
+		gradWeight := grad.Weight[i]
 		for j := 0; j < len(weight); j++ {
-			residual[j] += cost * weight[j]
-			weight[j] += rate * cost * input[j]
+			residual[j] += cost * float64(weight[j])
+			gradWeight[j] += cost * input[j]
+		}
+		if grad.Bias != nil {
+			grad.Bias[i] += cost
 		}
-		layer.Bias[i] += rate * cost
 	}
 	return
 }
 
-func (net *Network) Train(input []float64, expected []float64, rate float64, accel float64) {
-	err := make([]float64, len(net.Output.value))
-	for i := 0; i < len(err); i++ {
-		err[i] = expected[i] - net.Output.value[i]
+// applyGradient steps the weights and biases against the gradient
+// averaged over n examples, with L2 weight decay of strength lambda added
+// to the weights' gradients, as opt directs at the layer's scaled rate,
+// and then shrinks the weights by the decoupled decay. Batch
+// normalization's scales and shifts always take a plain gradient step.
+func (layer *Layer) applyGradient(grad *gradient, opt Optimizer, rate float64, lambda float64, decay float64, n int) {
+	if layer.Frozen {
+		return
+	}
+	if layer.RateScale != 0 {
+		rate *= layer.RateScale
+	}
+	size := opt.StateSize()
+	state := layer.optimizerState(opt)
+	step := func(g float64) float64 {
+		s := state[:size]
+		state = state[size:]
+		return opt.Step(g, rate, s)
+	}
+	for i, weight := range layer.Weight {
+		gradWeight := grad.Weight[i]
+		for j := 0; j < len(weight); j++ {
+			weight[j] -= Float(step(gradWeight[j]/float64(n) + lambda*float64(weight[j])))
+			weight[j] -= Float(rate * decay * float64(weight[j]))
+		}
+		if layer.Bias != nil {
+			layer.Bias[i] -= Float(step(grad.Bias[i] / float64(n)))
+		}
+	}
+	if bn := layer.BatchNorm; bn != nil {
+		scale := rate / float64(n)
+		for i := range bn.Gamma {
+			bn.Gamma[i] -= scale * grad.Gamma[i]
+			bn.Beta[i] -= scale * grad.Beta[i]
+		}
+	}
+}
+
+func (net *Network) zeroGradients() {
+	for _, layer := range net.Layers {
+		layer.gradient().zero()
+	}
+}
+
+// backward adds the gradient of the cost on one example, scaled by
+// weight, to each layer's gradient buffer, using the values cached by the
+// last forward pass.
+func (net *Network) backward(input []float64, expected []float64, weight float64) {
+	delta := net.outputDelta(expected)
+	if weight != 1 {
+		for i := range delta {
+			delta[i] *= weight
+		}
+	}
+	for i := len(net.Layers) - 1; i >= 0; i-- {
+		layer := net.Layers[i]
+		layerInput := input
+		if i > 0 {
+			layerInput = net.Layers[i-1].value
+		}
+		if layer.BatchNorm != nil {
+			layer.BatchNorm.backward(delta, layer.normalized, layer.gradient())
+		}
+		residual := layer.backpropagate(layerInput, delta, layer.gradient())
+		if i > 0 {
+			delta = net.Layers[i-1].delta(residual)
+		}
+	}
+}
+
+func (net *Network) applyGradients(opt Optimizer, rate float64, lambda float64, n int) {
+	for _, layer := range net.Layers {
+		layer.applyGradient(layer.gradient(), opt, rate, lambda, net.WeightDecay, n)
+	}
+}
+
+// Train runs input forward and adjusts the weights toward expected, using
+// the network's Optimizer. It returns the loss on the example from before
+// the update, as measured by the forward pass it trained on.
+func (net *Network) Train(input []float64, expected []float64, rate float64, accel float64) float64 {
+	return net.trainExample(input, expected, 1, rate, 0)
+}
+
+// TrainMomentum runs input forward and adjusts the weights toward expected
+// using classical momentum, whatever the network's Optimizer. The velocity
+// persists across calls for the life of the network, and SaveWithOptimizer
+// saves it. Like Train, it returns the loss from before the update.
+func (net *Network) TrainMomentum(input []float64, expected []float64, rate float64, momentum float64) float64 {
+	net.zeroGradients()
+	loss := net.cost(net.feedforwardTraining(input), expected)
+	net.backward(input, expected, 1)
+	net.applyGradients(Momentum{momentum}, rate, 0, 1)
+	return loss
+}
+
+// TrainRMSProp runs input forward and adjusts the weights toward expected
+// using RMSProp, whatever the network's Optimizer; see RMSProp for decay
+// and eps. Like Train, it returns the loss from before the update.
+func (net *Network) TrainRMSProp(input []float64, expected []float64, rate float64, decay float64, eps float64) float64 {
+	net.zeroGradients()
+	loss := net.cost(net.feedforwardTraining(input), expected)
+	net.backward(input, expected, 1)
+	net.applyGradients(RMSProp{decay, eps}, rate, 0, 1)
+	return loss
+}
+
+// TrainBatch makes a single update using the gradient averaged over every
+// example in the batch, with L2 regularization of strength lambda. Layers
+// with batch normalization are normalized using the batch's statistics.
+func (net *Network) TrainBatch(inputs [][]float64, expecteds [][]float64, rate float64, lambda float64) {
+	net.TrainBatchClipped(inputs, expecteds, rate, lambda, 0)
+}
+
+// TrainBatchClipped is TrainBatch, except that when the global L2 norm of
+// the averaged gradient across all layers exceeds clipNorm, the gradient is
+// scaled down to that norm before it's applied. A clipNorm of 0 disables
+// clipping. The L2 regularization term isn't included in the norm.
+func (net *Network) TrainBatchClipped(inputs [][]float64, expecteds [][]float64, rate float64, lambda float64, clipNorm float64) {
+	net.trainBatch(inputs, expecteds, nil, rate, lambda, clipNorm)
+}
+
+// TrainBatchWeighted is TrainBatch, with each example's contribution to
+// the gradient scaled by its weight, for example to make up for rare
+// classes. Weights of 1 train exactly as TrainBatch does. It panics if
+// there isn't one weight per example.
+func (net *Network) TrainBatchWeighted(inputs [][]float64, expecteds [][]float64, weights []float64, rate float64, lambda float64) {
+	if len(weights) != len(inputs) {
+		panic(fmt.Errorf("neural: got %d sample weights for %d examples", len(weights), len(inputs)))
+	}
+	net.trainBatch(inputs, expecteds, weights, rate, lambda, 0)
+}
+
+// trainBatch is TrainBatchClipped, with examples weighted as in
+// TrainBatchWeighted unless weights is nil, returning the weighted loss
+// summed over the batch before the update.
+func (net *Network) trainBatch(inputs [][]float64, expecteds [][]float64, weights []float64, rate float64, lambda float64, clipNorm float64) float64 {
+	if len(inputs) == 0 {
+		return 0
+	}
+	net.zeroGradients()
+	loss := net.accumulateBatch(inputs, expecteds, weights)
+	net.clipGradients(clipNorm, len(inputs))
+	net.applyGradients(net.optimizer(), rate, lambda, len(inputs))
+	return loss
+}
+
+// accumulateBatch adds the gradient of the cost on every example, scaled
+// by its weight, to the gradient buffers, and returns the total weighted
+// cost. A nil weights gives every example a weight of 1.
+func (net *Network) accumulateBatch(inputs [][]float64, expecteds [][]float64, weights []float64) float64 {
+	if net.hasBatchNorm() {
+		return net.accumulateBatchNorm(inputs, expecteds, weights)
 	}
-	residual := net.Output.backpropagate(net.Hidden.value, err, rate, accel)
-	net.Hidden.backpropagate(input, residual, rate, accel)
+	loss := 0.0
+	for i, input := range inputs {
+		weight := sampleWeight(weights, i)
+		loss += weight * net.cost(net.feedforwardTraining(input), expecteds[i])
+		net.backward(input, expecteds[i], weight)
+	}
+	return loss
+}
+
+// sampleWeight is the weight of example i: weights[i], or 1 without
+// weights.
+func sampleWeight(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+// clipGradients rescales the gradients, summed over n examples, so that the
+// norm of their average is at most maxNorm.
+func (net *Network) clipGradients(maxNorm float64, n int) {
+	if maxNorm <= 0 {
+		return
+	}
+	sum := 0.0
+	for _, layer := range net.Layers {
+		layer.gradient().each(func(g *float64) { sum += *g * *g })
+	}
+	norm := math.Sqrt(sum) / float64(n)
+	if norm <= maxNorm {
+		return
+	}
+	scale := maxNorm / norm
+	for _, layer := range net.Layers {
+		layer.gradient().each(func(g *float64) { *g *= scale })
+	}
+}
+
+// outputDelta is the gradient of the cost with respect to the output
+// layer's weighted sums.
+func (net *Network) outputDelta(expected []float64) []float64 {
+	output := net.outputLayer()
+	return net.outputDeltaFrom(output.sum, output.value, expected)
+}
+
+func (net *Network) outputDeltaFrom(sums []float64, values []float64, expected []float64) []float64 {
+	output := net.outputLayer()
+	loss := net.loss()
+	if _, ok := loss.(CrossEntropy); ok {
+		switch output.activation().(type) {
+		case Softmax, Sigmoid:
+			// These cancel out the cross-entropy's curvature, so applying
+			// their derivative here would count it twice.
+			return SquaredError{}.Gradient(values, expected)
+		}
+	}
+	return output.deltaFrom(loss.Gradient(values, expected), sums, values, nil)
 }
 
 func (net *Network) String() string {
-	return fmt.Sprintf(
-		"Hidden=[Weights=%v, Bias=%v]\n"+
-			"Output=[Weights=%v, Bias=%v]",
-		net.Hidden.Weight, net.Hidden.Bias,
-		net.Output.Weight, net.Output.Bias)
+	return net.FormatWeights(0)
+}
+
+// FormatWeights is String with each weight and bias rounded to precision
+// significant figures, for output short enough to read. A precision of 0
+// or less prints every digit, as String does.
+func (net *Network) FormatWeights(precision int) string {
+	if precision <= 0 {
+		precision = -1
+	}
+	parts := make([]string, len(net.Layers))
+	for i, layer := range net.Layers {
+		weights := make([]string, len(layer.Weight))
+		for j, weight := range layer.Weight {
+			weights[j] = formatVector(weight, precision)
+		}
+		parts[i] = fmt.Sprintf("%s=[Weights=[%s], Bias=%s]",
+			net.layerName(i), strings.Join(weights, " "), formatVector(layer.Bias, precision))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// formatVector formats v as fmt's %v would, with precision significant
+// figures.
+func formatVector(v []Float, precision int) string {
+	s := make([]string, len(v))
+	for i, x := range v {
+		s[i] = strconv.FormatFloat(float64(x), 'g', precision, floatBits)
+	}
+	return "[" + strings.Join(s, " ") + "]"
 }
 
-func (net *Network) Save(w io.Writer) {
+// SchemaVersion is the version of the format written by Save and SaveGob.
+// Files from older versions are migrated as they're loaded; saving a
+// loaded network writes it back at the current version.
+const SchemaVersion = 2
+
+func (net *Network) Save(w io.Writer) error {
+	saved, err := net.saved()
+	if err != nil {
+		return err
+	}
 	enc := json.NewEncoder(w)
-	enc.Encode(net)
+	return enc.Encode(saved)
+}
+
+// MarshalJSON encodes the network as Save does, so that it can be part of
+// a larger JSON document.
+func (net *Network) MarshalJSON() ([]byte, error) {
+	saved, err := net.saved()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(saved)
+}
+
+// UnmarshalJSON replaces the network with one decoded from data as
+// LoadNetwork decodes it.
+func (net *Network) UnmarshalJSON(data []byte) error {
+	loaded, err := LoadNetwork(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	*net = *loaded
+	return nil
+}
+
+// savedNetwork is the format of Save. Every field's JSON name is given
+// explicitly, so renaming a field doesn't change the format. Files written
+// before the names were lowercased still load, as decoding matches names
+// regardless of case.
+type savedNetwork struct {
+	Version int      `json:"version"`
+	Layers  []*Layer `json:"layers,omitempty"`
+	// Loss is the registered name of the loss. Before version 2 it was a
+	// number, with Huber's delta stored separately.
+	Loss            json.RawMessage `json:"loss,omitempty"`
+	LossParams      json.RawMessage `json:"lossParams,omitempty"`
+	HuberDelta      float64         `json:"huberDelta,omitempty"`
+	Optimizer       string          `json:"optimizer,omitempty"`
+	OptimizerParams json.RawMessage `json:"optimizerParams,omitempty"`
+	WeightDecay     float64         `json:"weightDecay,omitempty"`
+	// OptimizerState holds each layer's optimizer state when saved by
+	// SaveWithOptimizer, and StateOptimizer the optimizer it belongs to.
+	// Files from before it was added may hold momentum in Velocity.
+	OptimizerState [][]float64 `json:"optimizerState,omitempty"`
+	StateOptimizer string      `json:"stateOptimizer,omitempty"`
+	Velocity       []*gradient `json:"velocity,omitempty"`
+	// Unversioned files may have exactly one hidden and one output layer,
+	// stored here instead of in Layers.
+	Hidden *Layer `json:"hidden,omitempty"`
+	Output *Layer `json:"output,omitempty"`
+}
+
+func (net *Network) saved() (*savedNetwork, error) {
+	name, params, err := losses.marshal(net.loss())
+	if err != nil {
+		return nil, err
+	}
+	quoted, err := json.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+	saved := &savedNetwork{Version: SchemaVersion, Layers: net.Layers, Loss: quoted, LossParams: params, WeightDecay: net.WeightDecay}
+	if net.Optimizer != nil {
+		if saved.Optimizer, saved.OptimizerParams, err = optimizers.marshal(net.Optimizer); err != nil {
+			return nil, err
+		}
+	}
+	return saved, nil
+}
+
+// legacyLoss is the loss stored as the given number before version 2.
+func legacyLoss(n int, huberDelta float64) (Loss, error) {
+	switch n {
+	case 0:
+		return SquaredError{}, nil
+	case 1:
+		return CrossEntropy{}, nil
+	case 2:
+		return Huber{Delta: huberDelta}, nil
+	}
+	return nil, fmt.Errorf("neural: decoding network: unknown loss %d", n)
 }
 
-func LoadNetwork(r io.Reader) *Network {
-	net := new(Network)
+// migrate brings a network decoded from an older version of the format up
+// to date.
+func (saved *savedNetwork) migrate() error {
+	switch saved.Version {
+	case 0:
+		if len(saved.Layers) == 0 && (saved.Hidden != nil || saved.Output != nil) {
+			saved.Layers = []*Layer{saved.Hidden, saved.Output}
+		}
+		fallthrough
+	case 1:
+		var n int
+		if len(saved.Loss) > 0 {
+			if err := json.Unmarshal(saved.Loss, &n); err != nil {
+				return fmt.Errorf("neural: decoding network: %v", err)
+			}
+		}
+		loss, err := legacyLoss(n, saved.HuberDelta)
+		if err != nil {
+			return err
+		}
+		name, params, err := losses.marshal(loss)
+		if err != nil {
+			return err
+		}
+		if saved.Loss, err = json.Marshal(name); err != nil {
+			return err
+		}
+		saved.LossParams, saved.HuberDelta = params, 0
+	case SchemaVersion:
+	default:
+		return fmt.Errorf("neural: decoding network: unsupported schema version %d (this package reads up to version %d)",
+			saved.Version, SchemaVersion)
+	}
+	saved.Version = SchemaVersion
+	return nil
+}
+
+// LoadNetwork decodes a network written by Save or SaveWithOptimizer,
+// restoring the optimizer state too if it was saved.
+func LoadNetwork(r io.Reader) (*Network, error) {
+	saved := new(savedNetwork)
 	dec := json.NewDecoder(r)
-	dec.Decode(net)
-	net.Hidden.initialize()
-	net.Output.initialize()
-	return net
+	if err := dec.Decode(saved); err != nil {
+		return nil, fmt.Errorf("neural: decoding network: %v", err)
+	}
+	if err := saved.migrate(); err != nil {
+		return nil, err
+	}
+	var name string
+	if len(saved.Loss) > 0 {
+		if err := json.Unmarshal(saved.Loss, &name); err != nil {
+			return nil, fmt.Errorf("neural: decoding network: %v", err)
+		}
+	}
+	loss, err := unmarshalLoss(name, saved.LossParams)
+	if err != nil {
+		return nil, err
+	}
+	opt, err := unmarshalOptimizer(saved.Optimizer, saved.OptimizerParams)
+	if err != nil {
+		return nil, err
+	}
+	net := &Network{Layers: saved.Layers, Loss: loss, Optimizer: opt, WeightDecay: saved.WeightDecay}
+	if err := net.initialize(); err != nil {
+		return nil, err
+	}
+	if err := net.restoreState(saved); err != nil {
+		return nil, err
+	}
+	return net, nil
 }
 
-func MeanSquaredError(result []float64, expected []float64) float64 {
-	sum := 0.0
-	for i := 0; i < len(result); i++ {
-		sum += math.Pow(float64(expected[i]-result[i]), 2)
+// initialize checks that a decoded network's layers fit together and sets
+// up the buffers that aren't saved with them. It's Init, with errors
+// reported as decoding errors.
+func (net *Network) initialize() error {
+	if err := net.checkDimensions(); err != nil {
+		return fmt.Errorf("neural: decoding network: %v", err)
+	}
+	net.allocate()
+	return nil
+}
+
+// Init checks that a network assembled by hand, rather than by one of the
+// constructors or loaders, has layers that fit together, and allocates
+// the buffers training uses. Activate and training work without it, but
+// fail less clearly on layers that don't fit.
+func (net *Network) Init() error {
+	if err := net.checkDimensions(); err != nil {
+		return fmt.Errorf("neural: %v", err)
+	}
+	net.allocate()
+	return nil
+}
+
+// allocate sets up the buffers of layers that checkDimensions has passed.
+func (net *Network) allocate() {
+	for _, layer := range net.Layers {
+		layer.initialize()
+	}
+}
+
+// checkDimensions describes the first way in which the network's layers
+// don't fit together, if any: each needs at least one node, one row of
+// weights per node with one weight per node of the layer before it (or
+// per input), and biases and batch normalization for each node.
+func (net *Network) checkDimensions() error {
+	if len(net.Layers) == 0 {
+		return fmt.Errorf("no layers")
+	}
+	for i, layer := range net.Layers {
+		name := net.layerName(i)
+		if layer == nil {
+			return fmt.Errorf("missing %s layer", name)
+		}
+		nodes, inputs := len(layer.Weight), layer.inputs()
+		if nodes == 0 {
+			return fmt.Errorf("%s layer has no nodes", name)
+		}
+		if i == 0 && inputs == 0 {
+			return fmt.Errorf("%s layer has no inputs", name)
+		}
+		if i > 0 && net.Layers[i-1] != nil && inputs != len(net.Layers[i-1].Weight) {
+			return fmt.Errorf("%s layer has %d inputs, but %s layer has %d nodes",
+				name, inputs, net.layerName(i-1), len(net.Layers[i-1].Weight))
+		}
+		for j, weight := range layer.Weight {
+			if len(weight) != inputs {
+				return fmt.Errorf("%s weight row %d has %d inputs, want %d", name, j, len(weight), inputs)
+			}
+		}
+		if layer.Bias != nil && len(layer.Bias) != nodes {
+			return fmt.Errorf("%s layer has %d nodes but %d biases", name, nodes, len(layer.Bias))
+		}
+		if bn := layer.BatchNorm; bn != nil && (len(bn.Gamma) != nodes || len(bn.Beta) != nodes ||
+			len(bn.Mean) != nodes || len(bn.Variance) != nodes) {
+			return fmt.Errorf("%s batch normalization doesn't fit the layer's %d nodes", name, nodes)
+		}
 	}
-	return float64(sum) / float64(len(result))
+	return nil
 }
@@ -0,0 +1,191 @@
+package neural
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestSoftmaxDelta(t *testing.T) {
+	sums := []float64{0.5, -1.2, 2.0, 0.1}
+	err := []float64{0.3, -0.7, 0.2, 0.9}
+	for _, mask := range [][]float64{nil, {2, 0, 2, 0}} {
+		delta := softmaxDelta(append([]float64(nil), err...), sums, mask)
+		// The delta at each sum is the derivative of err · (mask * softmax(sums)).
+		dot := func(i int, h float64) float64 {
+			z := append([]float64(nil), sums...)
+			z[i] += h
+			Softmax{}.ApplyVector(z, z)
+			d := 0.0
+			for j := range z {
+				scale := 1.0
+				if mask != nil {
+					scale = mask[j]
+				}
+				d += err[j] * scale * z[j]
+			}
+			return d
+		}
+		const h = 1e-6
+		for i := range sums {
+			want := (dot(i, h) - dot(i, -h)) / (2 * h)
+			if math.Abs(delta[i]-want) > 1e-8 {
+				t.Errorf("mask %v: delta[%d] = %v, want %v", mask, i, delta[i], want)
+			}
+		}
+	}
+}
+
+func TestGradientCheck(t *testing.T) {
+	cases := []struct {
+		hidden, output Activation
+		loss           Loss
+	}{
+		{Sigmoid{}, Sigmoid{}, SquaredError{}},
+		{Sigmoid{}, Sigmoid{}, CrossEntropy{}},
+		{Tanh{}, Linear{}, Huber{Delta: 0.05}},
+		{Tanh{}, Softmax{}, CrossEntropy{}},
+		{Tanh{}, Softmax{}, SquaredError{}},
+		{Softmax{}, Sigmoid{}, SquaredError{}},
+		{Softplus{}, Linear{}, SquaredError{}},
+	}
+	// float32 weights can't take a perturbation as small, or give as
+	// precise a difference.
+	epsilon, tolerance := 1e-5, 1e-5
+	if floatBits == 32 {
+		epsilon, tolerance = 1e-3, 1e-2
+	}
+	for _, c := range cases {
+		rng := rand.New(rand.NewSource(1))
+		net := newDeepNetwork(3, []int{4, 5}, 3, Uniform, rng, c.hidden, c.output, true)
+		net.Loss = c.loss
+		before := net.String()
+		e := GradientCheck(net, []float64{0.3, -0.2, 0.9}, []float64{0.2, 0.7, 0.1}, epsilon)
+		if e > tolerance {
+			t.Errorf("%T hidden, %T output, %T loss: relative error %v", c.hidden, c.output, c.loss, e)
+		}
+		if net.String() != before {
+			t.Errorf("%T hidden, %T output, %T loss: GradientCheck changed the network", c.hidden, c.output, c.loss)
+		}
+	}
+}
+
+func TestLoadNetworkStateWithoutOptimizer(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewNetworkSeeded(2, 2, 1, 1).Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &saved); err != nil {
+		t.Fatal(err)
+	}
+	saved["optimizerState"] = [][]float64{{1}, {1}}
+	b, err := json.Marshal(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadNetwork(bytes.NewReader(b)); err == nil {
+		t.Error("loaded optimizer state without an optimizer")
+	}
+}
+
+func TestLoadKerasEmptyKernel(t *testing.T) {
+	model := `{"model": {"class_name": "Sequential", "config": [{"class_name": "Dense",
+		"config": {"name": "dense", "activation": "linear", "use_bias": false}}]}, "weights": [[[]]]}`
+	if net, err := LoadKeras(strings.NewReader(model)); err == nil {
+		t.Errorf("loaded %v", net)
+	}
+}
+
+func TestTopKAccuracyNaN(t *testing.T) {
+	nan := Float(math.NaN())
+	net := &Network{Layers: []*Layer{{
+		Weight:     [][]Float{{0}, {0}, {0}},
+		Bias:       []Float{nan, 0.5, 0.2},
+		Activation: Linear{},
+	}}}
+	if err := net.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// The NaN output for class 0 ranks behind both numbers.
+	inputs, expecteds := [][]float64{{1}}, [][]float64{{1, 0, 0}}
+	for k, want := range []float64{0, 0, 1} {
+		if got := TopKAccuracy(net, inputs, expecteds, k+1); got != want {
+			t.Errorf("top %d: accuracy %v, want %v", k+1, got, want)
+		}
+	}
+}
+
+// binaryHeader returns SaveBinary's format for a network with default
+// settings and one layer that claims the given dimensions, but ends
+// before the layer's weights.
+func binaryHeader(nodes, inputs int) []byte {
+	var buf bytes.Buffer
+	bw := &binaryWriter{w: bufio.NewWriter(&buf)}
+	bw.bytes([]byte(binaryMagic))
+	bw.uint32(binaryVersion)
+	bw.uint32(1)
+	for i := 0; i < 4; i++ {
+		bw.string("")
+	}
+	bw.float64(0)
+	bw.uint32(nodes)
+	bw.uint32(inputs)
+	bw.bytes([]byte{0})
+	bw.float64(0)
+	bw.float64(0)
+	bw.string("")
+	bw.string("")
+	bw.w.Flush()
+	return buf.Bytes()
+}
+
+func TestLoadNetworkBinaryHeader(t *testing.T) {
+	cases := []struct{ nodes, inputs int }{
+		{1 << 30, 1 << 30},
+		{200000000, 0},
+		{0, 3},
+	}
+	for _, c := range cases {
+		net, err := LoadNetworkBinary(bytes.NewReader(binaryHeader(c.nodes, c.inputs)))
+		if err == nil {
+			t.Errorf("%d nodes, %d inputs: loaded %v", c.nodes, c.inputs, net)
+		}
+	}
+}
+
+var benchmarkResult float64
+
+func BenchmarkSigmoid(b *testing.B) {
+	sum := 0.0
+	for i := 0; i < b.N; i++ {
+		x := float64(i%200)/10 - 10
+		sum += Sigmoid{}.Apply(x)
+	}
+	benchmarkResult = sum
+}
+
+// BenchmarkSigmoidPow times the math.Pow form Sigmoid used before, as a
+// baseline for BenchmarkSigmoid.
+func BenchmarkSigmoidPow(b *testing.B) {
+	sum := 0.0
+	for i := 0; i < b.N; i++ {
+		x := float64(i%200)/10 - 10
+		sum += 1.0 / (1.0 + math.Pow(math.E, -x))
+	}
+	benchmarkResult = sum
+}
+
+func BenchmarkActivate(b *testing.B) {
+	net := NewNetworkSeeded(8, 16, 4, 1)
+	input := []float64{0.1, 0.9, -0.3, 0.5, 0.2, -0.7, 0.4, 0.8}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchmarkResult = net.Activate(input)[0]
+	}
+}
@@ -0,0 +1,234 @@
+package neural
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ONNX versions ExportONNX writes: the IR version of the file format and
+// the version of the default operator set its nodes come from.
+const (
+	onnxIRVersion = 7
+	onnxOpset     = 13
+)
+
+// ONNX's enum values for float tensors and for float and int attributes.
+const (
+	onnxFloat     = 1
+	onnxAttrFloat = 1
+	onnxAttrInt   = 2
+)
+
+// ExportONNX writes the network as an ONNX model for inference in other
+// runtimes. Each layer becomes a Gemm node, followed by a
+// BatchNormalization node if it has batch normalization and a node for
+// its activation. The graph takes a batch of inputs named "input" and
+// produces "output"; weights are stored as 32-bit floats, and dropout is
+// left out as it is in Activate. Custom activations can't be exported.
+func (net *Network) ExportONNX(w io.Writer) error {
+	var graph protobuf
+	nodes := make([]onnxNode, 0, 2*len(net.Layers))
+	from := "input"
+	for i, layer := range net.Layers {
+		prefix := fmt.Sprintf("layer%d.", i+1)
+		gemm := onnxNode{op: "Gemm", inputs: []string{from, prefix + "weight"}, output: prefix + "gemm"}
+		gemm.attrs.addInt("transB", 1)
+		graph.message(5, onnxTensor(prefix+"weight", flattenWeights(layer.Weight), len(layer.Weight), layer.inputs()))
+		if layer.Bias != nil {
+			gemm.inputs = append(gemm.inputs, prefix+"bias")
+			graph.message(5, onnxTensor(prefix+"bias", flattenWeights([][]Float{layer.Bias}), len(layer.Bias)))
+		}
+		nodes = append(nodes, gemm)
+		if bn := layer.BatchNorm; bn != nil {
+			norm := onnxNode{op: "BatchNormalization", output: prefix + "batchnorm"}
+			norm.inputs = []string{gemm.output, prefix + "gamma", prefix + "beta", prefix + "mean", prefix + "variance"}
+			norm.attrs.addFloat("epsilon", batchNormEpsilon)
+			for j, values := range [][]float64{bn.Gamma, bn.Beta, bn.Mean, bn.Variance} {
+				graph.message(5, onnxTensor(norm.inputs[j+1], values, len(values)))
+			}
+			nodes = append(nodes, norm)
+		}
+		activation, ok, err := onnxActivation(layer.activation())
+		if err != nil {
+			return fmt.Errorf("neural: exporting %s layer: %v", net.layerName(i), err)
+		}
+		if ok {
+			activation.inputs = []string{nodes[len(nodes)-1].output}
+			activation.output = prefix + "activation"
+			nodes = append(nodes, activation)
+		}
+		from = nodes[len(nodes)-1].output
+	}
+	nodes[len(nodes)-1].output = "output"
+	for _, node := range nodes {
+		graph.message(1, node.encode())
+	}
+	graph.str(2, "neural")
+	graph.message(11, onnxValueInfo("input", net.InputSize()))
+	graph.message(12, onnxValueInfo("output", len(net.outputLayer().Weight)))
+
+	var opset, model protobuf
+	opset.varint(2, onnxOpset)
+	model.varint(1, onnxIRVersion)
+	model.str(2, "neural-go")
+	model.message(7, &graph)
+	model.message(8, &opset)
+	_, err := w.Write(model.Bytes())
+	return err
+}
+
+// onnxActivation returns the node computing activation, or false if the
+// activation is the identity and needs none.
+func onnxActivation(activation Activation) (onnxNode, bool, error) {
+	var node onnxNode
+	switch a := activation.(type) {
+	case Sigmoid:
+		node.op = "Sigmoid"
+	case Tanh:
+		node.op = "Tanh"
+	case ReLU:
+		node.op = "Relu"
+	case LeakyReLU:
+		node.op = "LeakyRelu"
+		node.attrs.addFloat("alpha", a.alpha())
+	case Softplus:
+		node.op = "Softplus"
+	case ELU:
+		node.op = "Elu"
+		node.attrs.addFloat("alpha", a.alpha())
+	case Softmax:
+		node.op = "Softmax"
+		node.attrs.addInt("axis", 1)
+	case Linear:
+		return node, false, nil
+	default:
+		return node, false, fmt.Errorf("activation %s has no ONNX equivalent", activationName(activation))
+	}
+	return node, true, nil
+}
+
+type onnxNode struct {
+	op     string
+	inputs []string
+	output string
+	// attrs holds the node's encoded AttributeProtos.
+	attrs onnxAttrs
+}
+
+func (node *onnxNode) encode() *protobuf {
+	var pb protobuf
+	for _, input := range node.inputs {
+		pb.str(1, input)
+	}
+	pb.str(2, node.output)
+	pb.str(3, node.output)
+	pb.str(4, node.op)
+	pb.Write(node.attrs.Bytes())
+	return &pb
+}
+
+type onnxAttrs struct {
+	protobuf
+}
+
+func (attrs *onnxAttrs) addInt(name string, value int64) {
+	var attr protobuf
+	attr.str(1, name)
+	attr.varint(3, uint64(value))
+	attr.varint(20, onnxAttrInt)
+	attrs.message(5, &attr)
+}
+
+func (attrs *onnxAttrs) addFloat(name string, value float64) {
+	var attr protobuf
+	attr.str(1, name)
+	attr.fixed32(2, math.Float32bits(float32(value)))
+	attr.varint(20, onnxAttrFloat)
+	attrs.message(5, &attr)
+}
+
+// onnxTensor encodes values as a float TensorProto of the given shape.
+func onnxTensor(name string, values []float64, dims ...int) *protobuf {
+	var tensor protobuf
+	for _, dim := range dims {
+		tensor.varint(1, uint64(dim))
+	}
+	tensor.varint(2, onnxFloat)
+	tensor.str(8, name)
+	raw := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(float32(v)))
+	}
+	tensor.bytes(9, raw)
+	return &tensor
+}
+
+// onnxValueInfo describes a graph input or output: a float tensor with a
+// batch of rows of the given width.
+func onnxValueInfo(name string, width int) *protobuf {
+	var batch, columns, shape, tensor, typ, info protobuf
+	batch.str(2, "N")
+	columns.varint(1, uint64(width))
+	shape.message(1, &batch)
+	shape.message(1, &columns)
+	tensor.varint(1, onnxFloat)
+	tensor.message(2, &shape)
+	typ.message(1, &tensor)
+	info.str(1, name)
+	info.message(2, &typ)
+	return &info
+}
+
+func flattenWeights(weights [][]Float) []float64 {
+	var flat []float64
+	for _, weight := range weights {
+		for _, w := range weight {
+			flat = append(flat, float64(w))
+		}
+	}
+	return flat
+}
+
+// protobuf writes just enough of the protocol buffer wire format to build
+// an ONNX model, one field at a time.
+type protobuf struct {
+	bytes.Buffer
+}
+
+func (pb *protobuf) key(field int, wireType int) {
+	pb.uvarint(uint64(field<<3 | wireType))
+}
+
+func (pb *protobuf) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	pb.Write(buf[:binary.PutUvarint(buf[:], v)])
+}
+
+func (pb *protobuf) varint(field int, v uint64) {
+	pb.key(field, 0)
+	pb.uvarint(v)
+}
+
+func (pb *protobuf) fixed32(field int, v uint32) {
+	pb.key(field, 5)
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	pb.Write(buf[:])
+}
+
+func (pb *protobuf) bytes(field int, b []byte) {
+	pb.key(field, 2)
+	pb.uvarint(uint64(len(b)))
+	pb.Write(b)
+}
+
+func (pb *protobuf) str(field int, s string) {
+	pb.bytes(field, []byte(s))
+}
+
+func (pb *protobuf) message(field int, m *protobuf) {
+	pb.bytes(field, m.Bytes())
+}
@@ -0,0 +1,128 @@
+package neural
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+)
+
+// Optimizer decides the step training takes on each weight and bias from
+// its gradient. State is the parameter's own StateSize values, which start
+// at zero and carry over from one step to the next. A nil Optimizer is
+// SGD.
+type Optimizer interface {
+	StateSize() int
+	Step(grad float64, rate float64, state []float64) float64
+}
+
+// SGD steps each parameter by rate times its gradient.
+type SGD struct{}
+
+func (SGD) StateSize() int { return 0 }
+
+func (SGD) Step(grad float64, rate float64, state []float64) float64 {
+	return rate * grad
+}
+
+// Momentum is classical momentum: each step is v = Momentum*v + rate*grad,
+// where v is the previous step.
+type Momentum struct {
+	Momentum float64
+}
+
+func (Momentum) StateSize() int { return 1 }
+
+func (opt Momentum) Step(grad float64, rate float64, state []float64) float64 {
+	state[0] = opt.Momentum*state[0] + rate*grad
+	return state[0]
+}
+
+// Nesterov is Nesterov accelerated gradient, which steps as if the
+// gradient had been measured after the momentum was applied. With the
+// velocity updated as v = Momentum*v - rate*grad, each weight moves by
+// -Momentum*v_prev + (1+Momentum)*v.
+type Nesterov struct {
+	Momentum float64
+}
+
+func (Nesterov) StateSize() int { return 1 }
+
+func (opt Nesterov) Step(grad float64, rate float64, state []float64) float64 {
+	prev := state[0]
+	state[0] = opt.Momentum*prev - rate*grad
+	return opt.Momentum*prev - (1+opt.Momentum)*state[0]
+}
+
+// RMSProp divides each step by a running average of the parameter's
+// squared gradients, s = Decay*s + (1-Decay)*grad^2, so that each moves at
+// a similar pace: the step is rate*grad/(sqrt(s) + Epsilon). A zero Decay
+// means 0.9, and a zero Epsilon 1e-8.
+type RMSProp struct {
+	Decay   float64
+	Epsilon float64
+}
+
+func (RMSProp) StateSize() int { return 1 }
+
+func (opt RMSProp) Step(grad float64, rate float64, state []float64) float64 {
+	decay, epsilon := opt.Decay, opt.Epsilon
+	if decay == 0 {
+		decay = 0.9
+	}
+	if epsilon == 0 {
+		epsilon = 1e-8
+	}
+	state[0] = decay*state[0] + (1-decay)*grad*grad
+	return rate * grad / (math.Sqrt(state[0]) + epsilon)
+}
+
+var optimizers = newRegistry("optimizer")
+
+func init() {
+	RegisterOptimizer("SGD", SGD{})
+	RegisterOptimizer("Momentum", Momentum{})
+	RegisterOptimizer("Nesterov", Nesterov{})
+	RegisterOptimizer("RMSProp", RMSProp{})
+}
+
+// RegisterOptimizer makes a custom optimizer known to SaveWithOptimizer
+// and LoadNetwork under the given name. Any exported fields of the
+// optimizer are saved along with it.
+func RegisterOptimizer(name string, opt Optimizer) {
+	optimizers.register(name, opt)
+}
+
+func (net *Network) optimizer() Optimizer {
+	if net.Optimizer == nil {
+		return SGD{}
+	}
+	return net.Optimizer
+}
+
+func unmarshalOptimizer(name string, params json.RawMessage) (Optimizer, error) {
+	if name == "" {
+		return nil, nil
+	}
+	opt, err := optimizers.unmarshal(name, params)
+	if err != nil {
+		return nil, err
+	}
+	return opt.(Optimizer), nil
+}
+
+// optimizerState returns the layer's optimizer state for opt: StateSize
+// values for each parameter, in the order applyGradient visits them. The
+// state starts over whenever the layer is trained with a different kind
+// of optimizer.
+func (layer *Layer) optimizerState(opt Optimizer) []float64 {
+	t := reflect.TypeOf(opt)
+	n := len(layer.Bias)
+	for _, weight := range layer.Weight {
+		n += len(weight)
+	}
+	n *= opt.StateSize()
+	if layer.stateType != t || len(layer.state) != n {
+		layer.state, layer.stateType = make([]float64, n), t
+	}
+	return layer.state
+}
@@ -0,0 +1,151 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// QuantizedNetwork is a network for inference only, with each layer's
+// weights stored as int8 to take a quarter of the space. Everything else,
+// including biases, stays as it was.
+type QuantizedNetwork struct {
+	Layers []*QuantizedLayer `json:"layers"`
+}
+
+// QuantizedLayer stores each weight w as a q with w ≈ Scale*(q-ZeroPoint),
+// the scale and zero point chosen per layer so the int8 range covers its
+// weights from smallest to largest.
+type QuantizedLayer struct {
+	Weight     [][]int8   `json:"weight"`
+	Scale      float64    `json:"scale"`
+	ZeroPoint  int8       `json:"zeroPoint"`
+	Bias       []float64  `json:"bias,omitempty"`
+	Activation Activation `json:"-"`
+	BatchNorm  *BatchNorm `json:"batchNorm,omitempty"`
+}
+
+// Quantize returns a copy of the network with its weights quantized to
+// int8. Its outputs differ from the network's by the rounding of each
+// weight to one of 256 levels; see AccuracyDelta.
+func (net *Network) Quantize() *QuantizedNetwork {
+	q := &QuantizedNetwork{Layers: make([]*QuantizedLayer, len(net.Layers))}
+	for i, layer := range net.Layers {
+		q.Layers[i] = layer.quantize()
+	}
+	return q
+}
+
+func (layer *Layer) quantize() *QuantizedLayer {
+	// The range always includes 0, so that zero weights stay exact.
+	lo, hi := 0.0, 0.0
+	for _, weight := range layer.Weight {
+		for _, w := range weight {
+			lo, hi = math.Min(lo, float64(w)), math.Max(hi, float64(w))
+		}
+	}
+	q := &QuantizedLayer{Scale: 1, Activation: layer.activation()}
+	if hi > lo {
+		q.Scale = (hi - lo) / 255
+		q.ZeroPoint = clampInt8(math.Round(math.MinInt8 - lo/q.Scale))
+	}
+	q.Weight = make([][]int8, len(layer.Weight))
+	for i, weight := range layer.Weight {
+		q.Weight[i] = make([]int8, len(weight))
+		for j, w := range weight {
+			q.Weight[i][j] = clampInt8(math.Round(float64(w)/q.Scale) + float64(q.ZeroPoint))
+		}
+	}
+	if layer.Bias != nil {
+		q.Bias = make([]float64, len(layer.Bias))
+		for i, b := range layer.Bias {
+			q.Bias[i] = float64(b)
+		}
+	}
+	if bn := layer.BatchNorm; bn != nil {
+		q.BatchNorm = &BatchNorm{
+			Gamma:    copyFloats(bn.Gamma),
+			Beta:     copyFloats(bn.Beta),
+			Mean:     copyFloats(bn.Mean),
+			Variance: copyFloats(bn.Variance),
+		}
+	}
+	return q
+}
+
+func clampInt8(x float64) int8 {
+	return int8(math.Max(math.MinInt8, math.Min(math.MaxInt8, x)))
+}
+
+// Activate runs input through the network, dequantizing the weights as it
+// goes, and returns the output. Like Network.Activate, it panics if input
+// is the wrong length, and is safe to call from several goroutines at
+// once.
+func (q *QuantizedNetwork) Activate(input []float64) []float64 {
+	if inputs := len(q.Layers[0].Weight[0]); len(input) != inputs {
+		panic(fmt.Errorf("neural: got %d inputs, network expects %d", len(input), inputs))
+	}
+	output := input
+	for _, layer := range q.Layers {
+		sums := make([]float64, len(layer.Weight))
+		for i, weight := range layer.Weight {
+			sum := 0.0
+			for j, w := range weight {
+				sum += float64(int(w)-int(layer.ZeroPoint)) * output[j]
+			}
+			sums[i] = layer.Scale * sum
+			if layer.Bias != nil {
+				sums[i] += layer.Bias[i]
+			}
+		}
+		if layer.BatchNorm != nil {
+			layer.BatchNorm.apply(sums)
+		}
+		applyActivation(layer.Activation, sums)
+		output = sums
+	}
+	return output
+}
+
+// AccuracyDelta is the Accuracy of the quantized network on inputs minus
+// that of net, the network it was quantized from, so that it's negative
+// by however much accuracy quantizing cost.
+func (q *QuantizedNetwork) AccuracyDelta(net *Network, inputs [][]float64, expecteds [][]float64) float64 {
+	if len(inputs) == 0 {
+		return 0
+	}
+	correct := 0
+	for i, input := range inputs {
+		if class(q.Activate(input)) == class(expecteds[i]) {
+			correct++
+		}
+	}
+	return float64(correct)/float64(len(inputs)) - Accuracy(net, inputs, expecteds)
+}
+
+// quantizedLayerPlain has QuantizedLayer's fields without its JSON
+// methods, as layerPlain does for Layer.
+type quantizedLayerPlain QuantizedLayer
+
+type quantizedLayerJSON struct {
+	*quantizedLayerPlain
+	Activation       string          `json:"activation,omitempty"`
+	ActivationParams json.RawMessage `json:"activationParams,omitempty"`
+}
+
+func (layer *QuantizedLayer) MarshalJSON() ([]byte, error) {
+	name, params, err := marshalActivation(layer.Activation)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(quantizedLayerJSON{(*quantizedLayerPlain)(layer), name, params})
+}
+
+func (layer *QuantizedLayer) UnmarshalJSON(data []byte) (err error) {
+	saved := quantizedLayerJSON{quantizedLayerPlain: (*quantizedLayerPlain)(layer)}
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return
+	}
+	layer.Activation, err = unmarshalActivation(saved.Activation, saved.ActivationParams)
+	return
+}
@@ -0,0 +1,45 @@
+package neural
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// random is the package's own generator, used for whatever isn't given a
+// generator or seed of its own: initializing networks, and shuffling
+// datasets and dropout for networks without a Rand. It's safe for
+// concurrent use.
+var (
+	source = &lockedSource{src: rand.NewSource(time.Now().UnixNano()).(rand.Source64)}
+	random = rand.New(source)
+)
+
+// SetSeed reseeds the package's generator, making everything that draws
+// on it reproducible. It doesn't affect math/rand's global generator.
+func SetSeed(seed int64) {
+	source.Seed(seed)
+}
+
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
@@ -0,0 +1,59 @@
+package neural
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// registry maps the types of a kind of pluggable value, like activations
+// or losses, to the names they're saved under. Values are saved as their
+// name and their exported fields in JSON.
+type registry struct {
+	kind  string
+	types map[string]reflect.Type
+	names map[reflect.Type]string
+}
+
+func newRegistry(kind string) *registry {
+	return &registry{kind: kind, types: map[string]reflect.Type{}, names: map[reflect.Type]string{}}
+}
+
+func (r *registry) register(name string, v interface{}) {
+	t := reflect.TypeOf(v)
+	r.types[name] = t
+	r.names[t] = name
+}
+
+func (r *registry) name(v interface{}) (string, bool) {
+	name, ok := r.names[reflect.TypeOf(v)]
+	return name, ok
+}
+
+func (r *registry) marshal(v interface{}) (name string, params json.RawMessage, err error) {
+	name, ok := r.name(v)
+	if !ok {
+		return "", nil, fmt.Errorf("neural: unregistered %s %T", r.kind, v)
+	}
+	if params, err = json.Marshal(v); err != nil {
+		return "", nil, err
+	}
+	if string(params) == "{}" {
+		params = nil
+	}
+	return
+}
+
+func (r *registry) unmarshal(name string, params json.RawMessage) (interface{}, error) {
+	t, ok := r.types[name]
+	if !ok {
+		return nil, fmt.Errorf("neural: unknown %s %q", r.kind, name)
+	}
+	v := reflect.New(t)
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, v.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,65 @@
+package neural
+
+import "math"
+
+// Scaler standardizes features to zero mean and unit variance. Fit it on
+// the training inputs, and save it alongside the network so that the same
+// scaling is applied at inference.
+type Scaler struct {
+	Mean   []float64
+	StdDev []float64
+}
+
+// Fit sets the scaler's per-feature mean and population standard
+// deviation from data.
+func (scaler *Scaler) Fit(data [][]float64) {
+	if len(data) == 0 {
+		scaler.Mean, scaler.StdDev = nil, nil
+		return
+	}
+	n := float64(len(data))
+	scaler.Mean = make([]float64, len(data[0]))
+	scaler.StdDev = make([]float64, len(data[0]))
+	for _, row := range data {
+		for j, x := range row {
+			scaler.Mean[j] += x / n
+		}
+	}
+	for _, row := range data {
+		for j, x := range row {
+			d := x - scaler.Mean[j]
+			scaler.StdDev[j] += d * d / n
+		}
+	}
+	for j := range scaler.StdDev {
+		scaler.StdDev[j] = math.Sqrt(scaler.StdDev[j])
+	}
+}
+
+// Transform returns a standardized copy of data. Features that were
+// constant in the data the scaler was fit on become 0.
+func (scaler *Scaler) Transform(data [][]float64) [][]float64 {
+	out := make([][]float64, len(data))
+	for i, row := range data {
+		out[i] = make([]float64, len(row))
+		for j, x := range row {
+			if scaler.StdDev[j] > 0 {
+				out[i][j] = (x - scaler.Mean[j]) / scaler.StdDev[j]
+			}
+		}
+	}
+	return out
+}
+
+// InverseTransform undoes Transform, returning the data in its original
+// units.
+func (scaler *Scaler) InverseTransform(data [][]float64) [][]float64 {
+	out := make([][]float64, len(data))
+	for i, row := range data {
+		out[i] = make([]float64, len(row))
+		for j, x := range row {
+			out[i][j] = x*scaler.StdDev[j] + scaler.Mean[j]
+		}
+	}
+	return out
+}
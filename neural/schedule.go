@@ -0,0 +1,58 @@
+package neural
+
+import "math"
+
+// Schedule gives the learning rate to use for each epoch, counting from 0.
+type Schedule interface {
+	Rate(epoch int) float64
+}
+
+// ConstantRate uses the same learning rate for every epoch.
+type ConstantRate float64
+
+func (rate ConstantRate) Rate(epoch int) float64 {
+	return float64(rate)
+}
+
+// StepDecay multiplies the Initial rate by Factor once every Step epochs,
+// so StepDecay{0.1, 0.1, 50} trains at 0.1 for 50 epochs, then 0.01.
+type StepDecay struct {
+	Initial float64
+	Factor  float64
+	Step    int
+}
+
+func (s StepDecay) Rate(epoch int) float64 {
+	if s.Step <= 0 {
+		return s.Initial
+	}
+	return s.Initial * math.Pow(s.Factor, float64(epoch/s.Step))
+}
+
+// ExponentialDecay decays the Initial rate continuously, as
+// Initial*exp(-Decay*epoch).
+type ExponentialDecay struct {
+	Initial float64
+	Decay   float64
+}
+
+func (s ExponentialDecay) Rate(epoch int) float64 {
+	return s.Initial * math.Exp(-s.Decay*float64(epoch))
+}
+
+// Warmup ramps the rate up linearly over the first Steps epochs before
+// handing off to Schedule, which then starts from its own epoch 0. Epoch e
+// of the warm-up trains at (e+1)/(Steps+1) of Schedule's first rate, so
+// Warmup{ConstantRate(0.1), 4} trains at 0.02, 0.04, 0.06 and 0.08, then
+// 0.1 from then on.
+type Warmup struct {
+	Schedule Schedule
+	Steps    int
+}
+
+func (s Warmup) Rate(epoch int) float64 {
+	if epoch < s.Steps {
+		return s.Schedule.Rate(0) * float64(epoch+1) / float64(s.Steps+1)
+	}
+	return s.Schedule.Rate(epoch - s.Steps)
+}
@@ -0,0 +1,85 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// TrainTestSplit shuffles a dataset and holds out testFraction of it for
+// testing. The same seed always gives the same split. The returned slices
+// share their rows with inputs and expecteds.
+func TrainTestSplit(inputs [][]float64, expecteds [][]float64, testFraction float64, seed int64) (trainIn, trainOut, testIn, testOut [][]float64) {
+	rng := rand.New(rand.NewSource(seed))
+	order := rng.Perm(len(inputs))
+	nTest := int(math.Round(testFraction * float64(len(inputs))))
+	trainIn, trainOut = pick(inputs, expecteds, order[nTest:])
+	testIn, testOut = pick(inputs, expecteds, order[:nTest])
+	return
+}
+
+// StratifiedTrainTestSplit is TrainTestSplit, but holds out testFraction
+// of each class separately, so that both sets keep the dataset's class
+// proportions. Classes are read from expecteds as in Accuracy.
+func StratifiedTrainTestSplit(inputs [][]float64, expecteds [][]float64, testFraction float64, seed int64) (trainIn, trainOut, testIn, testOut [][]float64) {
+	rng := rand.New(rand.NewSource(seed))
+	byClass := map[int][]int{}
+	var classes []int
+	for i, expected := range expecteds {
+		c := class(expected)
+		if _, ok := byClass[c]; !ok {
+			classes = append(classes, c)
+		}
+		byClass[c] = append(byClass[c], i)
+	}
+	var train, test []int
+	for _, c := range classes {
+		members := byClass[c]
+		rng.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+		nTest := int(math.Round(testFraction * float64(len(members))))
+		test = append(test, members[:nTest]...)
+		train = append(train, members[nTest:]...)
+	}
+	// Mix the classes back together.
+	rng.Shuffle(len(train), func(i, j int) { train[i], train[j] = train[j], train[i] })
+	rng.Shuffle(len(test), func(i, j int) { test[i], test[j] = test[j], test[i] })
+	trainIn, trainOut = pick(inputs, expecteds, train)
+	testIn, testOut = pick(inputs, expecteds, test)
+	return
+}
+
+// CrossValidate splits the dataset into k folds of consecutive examples,
+// and for each fold trains a network from newNet on the other k-1 with
+// TrainDataset, then measures its DatasetCost on the held-out fold. It
+// returns those costs, one per fold. Shuffle the dataset first if its
+// order isn't already random. It panics if k isn't between 2 and the
+// number of examples.
+func CrossValidate(inputs [][]float64, expecteds [][]float64, k int, newNet func() *Network, epochs int, schedule Schedule, lambda float64) []float64 {
+	if k < 2 || k > len(inputs) {
+		panic(fmt.Sprintf("neural: can't cross-validate %d examples with %d folds", len(inputs), k))
+	}
+	costs := make([]float64, k)
+	for fold := 0; fold < k; fold++ {
+		start, end := fold*len(inputs)/k, (fold+1)*len(inputs)/k
+		var train []int
+		for i := range inputs {
+			if i < start || i >= end {
+				train = append(train, i)
+			}
+		}
+		trainIn, trainOut := pick(inputs, expecteds, train)
+		net := newNet()
+		net.TrainDataset(trainIn, trainOut, epochs, schedule, lambda)
+		costs[fold] = net.DatasetCost(inputs[start:end], expecteds[start:end], lambda)
+	}
+	return costs
+}
+
+func pick(inputs [][]float64, expecteds [][]float64, indices []int) (pickedIn, pickedOut [][]float64) {
+	pickedIn = make([][]float64, len(indices))
+	pickedOut = make([][]float64, len(indices))
+	for i, j := range indices {
+		pickedIn[i], pickedOut[i] = inputs[j], expecteds[j]
+	}
+	return
+}
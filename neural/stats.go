@@ -0,0 +1,80 @@
+package neural
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// LayerStats summarizes the distribution of a layer's weights. StdDev is
+// the population standard deviation.
+type LayerStats struct {
+	Min, Max     float64
+	Mean, StdDev float64
+}
+
+// WeightStats summarizes each layer's weights, not counting biases. The
+// layers are keyed "hidden" (or "hidden1", "hidden2", ... when there are
+// several) and "output".
+func (net *Network) WeightStats() map[string]LayerStats {
+	stats := make(map[string]LayerStats, len(net.Layers))
+	for i, layer := range net.Layers {
+		stats[strings.ToLower(net.layerName(i))] = layer.weightStats()
+	}
+	return stats
+}
+
+func (layer *Layer) weightStats() LayerStats {
+	stats := LayerStats{Min: math.Inf(1), Max: math.Inf(-1)}
+	n, sum, sumSquares := 0, 0.0, 0.0
+	for _, weight := range layer.Weight {
+		for _, w := range weight {
+			x := float64(w)
+			stats.Min = math.Min(stats.Min, x)
+			stats.Max = math.Max(stats.Max, x)
+			sum += x
+			sumSquares += x * x
+			n++
+		}
+	}
+	if n == 0 {
+		return LayerStats{}
+	}
+	stats.Mean = sum / float64(n)
+	stats.StdDev = math.Sqrt(math.Max(sumSquares/float64(n)-stats.Mean*stats.Mean, 0))
+	return stats
+}
+
+// Validate returns an error describing the first layer whose dimensions
+// don't fit its neighbours', or else naming the first weight or bias that
+// is NaN or infinite, as happens when training diverges.
+func (net *Network) Validate() error {
+	if err := net.checkDimensions(); err != nil {
+		return fmt.Errorf("neural: %v", err)
+	}
+	for i, layer := range net.Layers {
+		for j, weight := range layer.Weight {
+			for k, w := range weight {
+				if !isFinite(float64(w)) {
+					return fmt.Errorf("neural: %s weight [%d][%d] is %v", net.layerName(i), j, k, w)
+				}
+			}
+		}
+		for j, b := range layer.Bias {
+			if !isFinite(float64(b)) {
+				return fmt.Errorf("neural: %s bias [%d] is %v", net.layerName(i), j, b)
+			}
+		}
+	}
+	return nil
+}
+
+// HasInvalidWeights reports whether any weight or bias is NaN or infinite,
+// or the layers don't fit together; see Validate.
+func (net *Network) HasInvalidWeights() bool {
+	return net.Validate() != nil
+}
+
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
@@ -0,0 +1,60 @@
+package neural
+
+import (
+	"bytes"
+	"fmt"
+	"text/tabwriter"
+)
+
+// Summary describes the network one layer per line: its name, input and
+// output sizes, activation and number of trainable parameters, followed
+// by the total parameter count.
+func (net *Network) Summary() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Layer\tInputs\tOutputs\tActivation\tParameters")
+	for i, layer := range net.Layers {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\n", net.layerName(i), layer.inputs(),
+			len(layer.Weight), activationName(layer.activation()), layer.numParameters())
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "Total parameters: %d\n", net.NumParameters())
+	return buf.String()
+}
+
+// NumParameters is the number of trainable values in the network: every
+// weight and bias, and the scales and shifts of batch-normalized layers.
+func (net *Network) NumParameters() int {
+	n := 0
+	for _, layer := range net.Layers {
+		n += layer.numParameters()
+	}
+	return n
+}
+
+func (layer *Layer) inputs() int {
+	if len(layer.Weight) == 0 {
+		return 0
+	}
+	return len(layer.Weight[0])
+}
+
+// numParameters counts the layer's weights, biases and batch
+// normalization scales and shifts.
+func (layer *Layer) numParameters() int {
+	n := len(layer.Bias)
+	for _, weight := range layer.Weight {
+		n += len(weight)
+	}
+	if layer.BatchNorm != nil {
+		n += len(layer.BatchNorm.Gamma) + len(layer.BatchNorm.Beta)
+	}
+	return n
+}
+
+func activationName(a Activation) string {
+	if name, ok := activations.name(a); ok {
+		return name
+	}
+	return fmt.Sprintf("%T", a)
+}
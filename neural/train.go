@@ -0,0 +1,320 @@
+package neural
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// trainExample runs one forward and backward pass and updates the weights,
+// with the example weighted by weight, returning its weighted loss before
+// the update.
+func (net *Network) trainExample(input []float64, expected []float64, weight float64, rate float64, lambda float64) float64 {
+	net.zeroGradients()
+	loss := weight * net.cost(net.feedforwardTraining(input), expected)
+	net.backward(input, expected, weight)
+	net.applyGradients(net.optimizer(), rate, lambda, 1)
+	return loss
+}
+
+// trainEpoch trains net on every example once, in a random order, making
+// an update for each AccumulateSteps batches of BatchSize examples, and
+// returns the mean loss, and with TrackGradients, each layer's mean
+// absolute gradient. After each batch it calls BatchProgress, if set; if
+// that returns false, the epoch ends early and stopped is true. It stops
+// between batches if ctx is done.
+func (trainer *Trainer) trainEpoch(ctx context.Context, net *Network, inputs [][]float64, expecteds [][]float64,
+	epoch int, rate float64) (loss float64, gradients []float64, stopped bool, err error) {
+	batchSize := trainer.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	steps := trainer.AccumulateSteps
+	if steps < 1 {
+		steps = 1
+	}
+	rng := net.rand()
+	order := rng.Perm(len(inputs))
+	if trainer.TrackGradients {
+		gradients = make([]float64, len(net.Layers))
+	}
+	total, done, batch := 0.0, 0, 0
+	// pending counts the examples whose gradients are waiting to be applied.
+	pending, updates := 0, 0
+	// finish averages the gradients over the updates made.
+	finish := func() []float64 {
+		for l := range gradients {
+			if updates > 0 {
+				gradients[l] /= float64(updates)
+			}
+		}
+		return gradients
+	}
+	for ; done < len(order); batch++ {
+		if err := ctx.Err(); err != nil {
+			return 0, nil, false, err
+		}
+		end := done + batchSize
+		if end > len(order) {
+			end = len(order)
+		}
+		batchInputs, batchExpecteds := pick(inputs, expecteds, order[done:end])
+		var batchWeights []float64
+		if trainer.SampleWeights != nil {
+			batchWeights = make([]float64, end-done)
+			for k, i := range order[done:end] {
+				batchWeights[k] = trainer.SampleWeights[i]
+			}
+		}
+		if trainer.Mixup > 0 {
+			for k := range batchInputs {
+				j := rng.Intn(len(inputs))
+				batchInputs[k], batchExpecteds[k] = mixup(batchInputs[k], batchExpecteds[k], inputs[j], expecteds[j], trainer.Mixup, rng)
+			}
+		}
+		if pending == 0 {
+			net.zeroGradients()
+		}
+		batchLoss := net.accumulateExamples(batchInputs, batchExpecteds, batchWeights)
+		total += batchLoss
+		n := end - done
+		done = end
+		pending += n
+		if (batch+1)%steps == 0 || done == len(order) {
+			for l, layer := range net.Layers[:len(gradients)] {
+				gradients[l] += layer.gradient().meanAbsWeight() / float64(pending)
+			}
+			net.applyGradients(net.optimizer(), rate, trainer.Lambda, pending)
+			pending = 0
+			updates++
+		}
+		if trainer.BatchProgress != nil && !trainer.BatchProgress(epoch, batch, batchLoss/float64(n)) {
+			return total / float64(done), finish(), true, nil
+		}
+	}
+	if len(inputs) == 0 {
+		return 0, finish(), false, nil
+	}
+	return total / float64(len(inputs)), finish(), false, nil
+}
+
+// accumulateExamples is accumulateBatch, except that a single example goes
+// through the layers as it would in trainExample, using the running batch
+// normalization statistics rather than those of a batch of one.
+func (net *Network) accumulateExamples(inputs [][]float64, expecteds [][]float64, weights []float64) float64 {
+	if len(inputs) != 1 {
+		return net.accumulateBatch(inputs, expecteds, weights)
+	}
+	weight := sampleWeight(weights, 0)
+	loss := weight * net.cost(net.feedforwardTraining(inputs[0]), expecteds[0])
+	net.backward(inputs[0], expecteds[0], weight)
+	return loss
+}
+
+// TrainDataset trains on every example once per epoch, visiting them in a
+// fresh random order each time. It returns the mean loss of each epoch,
+// measured on each example just before the network was trained on it.
+// The learning rate for each epoch comes from schedule.
+func (net *Network) TrainDataset(inputs [][]float64, expecteds [][]float64, epochs int, schedule Schedule, lambda float64) []float64 {
+	trainer := &Trainer{Epochs: epochs, Schedule: schedule, Lambda: lambda}
+	history, _ := trainer.Train(net, inputs, expecteds)
+	return history.Loss
+}
+
+// PartialFit trains on every example once, in a random order, and returns
+// the mean loss, as one epoch of TrainDataset at the given rate would. The
+// optimizer's state carries over from one call to the next, so calling it
+// on each chunk of a dataset as it arrives, or once per epoch of a loop
+// of the caller's own, trains as TrainDataset does.
+func (net *Network) PartialFit(inputs [][]float64, expecteds [][]float64, rate float64, lambda float64) float64 {
+	trainer := &Trainer{Epochs: 1, Schedule: ConstantRate(rate), Lambda: lambda}
+	history, _ := trainer.Train(net, inputs, expecteds)
+	return history.Loss[0]
+}
+
+// TrainDatasetContext is TrainDataset, except that it stops as soon as ctx
+// is done, between batches, and returns ctx.Err(). The network keeps the
+// training it had received, and the losses of the epochs it completed are
+// returned.
+func (net *Network) TrainDatasetContext(ctx context.Context, inputs [][]float64, expecteds [][]float64, epochs int, schedule Schedule, lambda float64) ([]float64, error) {
+	trainer := &Trainer{Epochs: epochs, Schedule: schedule, Lambda: lambda}
+	history, _, err := trainer.TrainContext(ctx, net, inputs, expecteds)
+	return history.Loss, err
+}
+
+// Example is one training input and the output expected for it.
+type Example struct {
+	Input    []float64
+	Expected []float64
+}
+
+// TrainStream trains on each example as it's received, until examples is
+// closed.
+func (net *Network) TrainStream(examples <-chan Example, rate float64, lambda float64) {
+	for example := range examples {
+		net.trainExample(example.Input, example.Expected, 1, rate, lambda)
+	}
+}
+
+// Trainer holds the settings for training a network over a dataset for a
+// number of epochs, as TrainDataset does.
+type Trainer struct {
+	Epochs   int
+	Schedule Schedule
+	Lambda   float64
+	// BatchSize, if more than 1, makes training update the network once
+	// per shuffled batch of that many examples, as TrainBatch does,
+	// rather than after every example.
+	BatchSize int
+	// Mixup, if positive, replaces each training example with a Mixup of
+	// it and another example drawn at random, using Mixup as alpha. The
+	// losses reported are then on the blended examples.
+	Mixup float64
+	// SampleWeights, if set, holds a weight for each training example,
+	// scaling its contribution to the gradient and to the losses reported.
+	SampleWeights []float64
+	// AccumulateSteps, if more than 1, sums the gradients of that many
+	// batches before applying them in one update, for the effect of a
+	// batch AccumulateSteps times as large without holding it all at once.
+	// Gradients still waiting when BatchProgress stops training are
+	// dropped.
+	AccumulateSteps int
+
+	// TrackGradients records each layer's mean absolute weight gradient,
+	// averaged over the epoch's updates, in the History, so that layers
+	// whose gradients have vanished can be found with VanishingLayers.
+	TrackGradients bool
+
+	// If validation data is given, its DatasetCost is measured after
+	// every epoch, and training stops early once it hasn't improved for
+	// Patience epochs. A Patience of 0 never stops early.
+	ValidationInputs    [][]float64
+	ValidationExpecteds [][]float64
+	Patience            int
+
+	// Progress, if set, is called with the mean loss after every epoch.
+	// Returning false stops training.
+	Progress func(epoch int, loss float64) bool
+	// BatchProgress, if set, is called with the mean loss of every batch,
+	// counting each example as a batch when BatchSize is 0 or 1.
+	// Returning false stops training in the middle of the epoch, whose
+	// loss so far is the last one returned.
+	BatchProgress func(epoch int, batch int, loss float64) bool
+
+	// CheckFinite stops training with the error from Validate after any
+	// epoch that leaves a weight or bias NaN or infinite.
+	CheckFinite bool
+
+	// Checkpoint, if set, is called with the network after every
+	// CheckpointEvery epochs (every epoch if CheckpointEvery is 0), for
+	// example to save it. An error stops training and is returned.
+	Checkpoint      func(epoch int, net *Network) error
+	CheckpointEvery int
+}
+
+// History records a training run, epoch by epoch: the mean training loss,
+// the validation cost if there was validation data, and the learning rate.
+// It encodes to JSON as it is.
+type History struct {
+	Loss           []float64
+	ValidationLoss []float64 `json:",omitempty"`
+	Rate           []float64
+	// Gradients holds, with the Trainer's TrackGradients, the mean
+	// absolute gradient of each layer's weights in each epoch.
+	Gradients [][]float64 `json:",omitempty"`
+}
+
+// BestEpoch is the epoch with the lowest validation cost, or without
+// validation data, the lowest training loss. It's -1 if no epoch was
+// recorded.
+func (history *History) BestEpoch() int {
+	losses := history.ValidationLoss
+	if len(losses) == 0 {
+		losses = history.Loss
+	}
+	best := -1
+	for epoch, loss := range losses {
+		if best < 0 || loss < losses[best] {
+			best = epoch
+		}
+	}
+	return best
+}
+
+// VanishingLayers returns the layers whose mean absolute weight gradient
+// in the last recorded epoch was below threshold, a sign that they've
+// stopped learning. It's empty unless gradients were tracked.
+func (history *History) VanishingLayers(threshold float64) []int {
+	var layers []int
+	if len(history.Gradients) == 0 {
+		return layers
+	}
+	for l, g := range history.Gradients[len(history.Gradients)-1] {
+		if g < threshold {
+			layers = append(layers, l)
+		}
+	}
+	return layers
+}
+
+// Train trains net in place and returns the history of the run. With
+// validation data, it also returns a copy of the network as it was after
+// the epoch with the lowest validation cost, history.BestEpoch(); otherwise
+// best is net itself after the last epoch. An empty dataset leaves the
+// network as it was, with a loss of 0 for every epoch.
+func (trainer *Trainer) Train(net *Network, inputs [][]float64, expecteds [][]float64) (history *History, best *Network) {
+	history, best, _ = trainer.TrainContext(context.Background(), net, inputs, expecteds)
+	return
+}
+
+// TrainContext is Train, except that it stops as soon as ctx is done and
+// returns ctx.Err() along with the results so far; see TrainDatasetContext.
+func (trainer *Trainer) TrainContext(ctx context.Context, net *Network, inputs [][]float64, expecteds [][]float64) (history *History, best *Network, err error) {
+	validate := len(trainer.ValidationInputs) > 0
+	history, best = new(History), net
+	if trainer.SampleWeights != nil && len(trainer.SampleWeights) != len(inputs) {
+		err = fmt.Errorf("neural: got %d sample weights for %d examples", len(trainer.SampleWeights), len(inputs))
+		return
+	}
+	bestEpoch, bestCost := -1, math.Inf(1)
+	for epoch := 0; epoch < trainer.Epochs; epoch++ {
+		rate := trainer.Schedule.Rate(epoch)
+		var loss float64
+		var gradients []float64
+		var stopped bool
+		if loss, gradients, stopped, err = trainer.trainEpoch(ctx, net, inputs, expecteds, epoch, rate); err != nil {
+			return
+		}
+		history.Loss = append(history.Loss, loss)
+		history.Rate = append(history.Rate, rate)
+		if gradients != nil {
+			history.Gradients = append(history.Gradients, gradients)
+		}
+		if stopped {
+			return
+		}
+		if trainer.CheckFinite {
+			if err = net.Validate(); err != nil {
+				return
+			}
+		}
+		if trainer.Checkpoint != nil && (trainer.CheckpointEvery <= 1 || (epoch+1)%trainer.CheckpointEvery == 0) {
+			if err = trainer.Checkpoint(epoch, net); err != nil {
+				return
+			}
+		}
+		if validate {
+			cost := net.DatasetCost(trainer.ValidationInputs, trainer.ValidationExpecteds, trainer.Lambda)
+			history.ValidationLoss = append(history.ValidationLoss, cost)
+			if cost < bestCost {
+				bestCost, best, bestEpoch = cost, net.Clone(), epoch
+			} else if trainer.Patience > 0 && epoch-bestEpoch >= trainer.Patience {
+				break
+			}
+		}
+		if trainer.Progress != nil && !trainer.Progress(epoch, loss) {
+			break
+		}
+	}
+	return
+}
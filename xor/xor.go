@@ -8,9 +8,9 @@ import (
 
 func main() {
 	const epsilon = 0.001
-	training := [][3]neural.Float{{0.1, 0.1, 0.1}, {0.1, 0.9, 0.9}, {0.9, 0.1, 0.9}, {0.9, 0.9, 0.1}}
+	training := [][3]float64{{0.1, 0.1, 0.1}, {0.1, 0.9, 0.9}, {0.9, 0.1, 0.9}, {0.9, 0.9, 0.1}}
 	net := neural.NewNetwork(2, 3, 1)
-	epoch, worst := 0, neural.Float(epsilon)
+	epoch, worst := 0, float64(epsilon)
 	for ; worst >= epsilon; epoch++ {
 		worst = 0.0
 		for _, sample := range training {